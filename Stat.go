@@ -292,6 +292,10 @@ func RegisterStatsHandlers(mux ...*http.ServeMux) {
 		for _, m := range muxes {
 			m.HandleFunc(statPrefix+"stats", HandleStats)
 			m.HandleFunc(statPrefix+"config", HandleConfig)
+			m.HandleFunc(statPrefix+"config.json", HandleConfigJSON)
+			m.HandleFunc(statPrefix+"config/docs", HandleConfigDocs)
+			m.HandleFunc(statPrefix+"api/config", HandleConfigAPI)
+			m.HandleFunc(statPrefix+"flags", HandleFlags)
 			m.HandleFunc(statPrefix+"reset", ResetStats)
 			m.HandleFunc(statPrefix+"", HandleOther)
 		}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/ordishs/gocore"
+)
+
+const usage = `Usage:
+  gocore-config export [flags]
+      Resolve every setting for a context and application and write the
+      effective values as an env file, JSON or a Kubernetes ConfigMap.
+
+Export flags:
+`
+
+var reConfigMapKey = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+type exportOptions struct {
+	format     string
+	secrets    string
+	name       string
+	envPrefix  string
+	secretsOut string
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "export" {
+		fmt.Print(usage)
+		exportFlags(&exportOptions{}, new(string), new(string), new(string), new(string)).PrintDefaults()
+		os.Exit(2)
+	}
+
+	var (
+		opts    exportOptions
+		context string
+		app     string
+		files   string
+		output  string
+	)
+
+	fs := exportFlags(&opts, &context, &app, &files, &output)
+	_ = fs.Parse(os.Args[2:])
+
+	if err := export(opts, context, app, strings.Split(files, ","), output); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func exportFlags(opts *exportOptions, context, app, files, output *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+
+	fs.StringVar(context, "context", "dev", "Context to resolve settings for")
+	fs.StringVar(app, "app", "", "Application to resolve settings for")
+	fs.StringVar(files, "files", strings.Join(gocore.SettingsFiles(), ","), "Comma separated settings files, in the order they are loaded")
+	fs.StringVar(output, "o", "", "Output file (default stdout)")
+	fs.StringVar(&opts.format, "format", "env", "Output format: env, json or configmap")
+	fs.StringVar(&opts.secrets, "secrets", "encrypted", "How to export secrets: encrypted keeps them in their *EHE* form, split decrypts them into a separate Secret manifest or -secrets-out file")
+	fs.StringVar(&opts.secretsOut, "secrets-out", "", "With -secrets split and the env or json format, the file to write secrets to")
+	fs.StringVar(&opts.name, "name", "", "ConfigMap name (default the app name, or settings).  The Secret is named <name>-secrets")
+	fs.StringVar(&opts.envPrefix, "env-prefix", "", "Write keys as prefixed, upper case environment variable names, as read with SETTINGS_ENV_PREFIX")
+
+	return fs
+}
+
+func export(opts exportOptions, context, app string, files []string, output string) error {
+	if opts.secrets != "encrypted" && opts.secrets != "split" {
+		return fmt.Errorf("unknown -secrets mode %q, expected encrypted or split", opts.secrets)
+	}
+
+	split := opts.secrets == "split"
+	if split && opts.format != "configmap" && opts.secretsOut == "" {
+		return fmt.Errorf("-secrets split needs -secrets-out for the %s format", opts.format)
+	}
+
+	cfg := gocore.NewConfiguration(context, app)
+	for _, filename := range files {
+		filename = strings.TrimSpace(filename)
+		if filename == "" {
+			continue
+		}
+		if err := cfg.LoadSettingsFile(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	var mapping gocore.EnvMapping
+	if opts.envPrefix != "" {
+		mapping = gocore.EnvMapping{Prefix: opts.envPrefix, DotSeparator: "__", UpperCase: true}
+	}
+
+	var values, secrets []gocore.ExportedSetting
+	for _, s := range cfg.Export(split) {
+		s.Key = mapping.EnvName(s.Key)
+		if split && s.Secret {
+			secrets = append(secrets, s)
+		} else {
+			values = append(values, s)
+		}
+	}
+
+	if opts.name == "" {
+		opts.name = app
+		if opts.name == "" {
+			opts.name = "settings"
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch opts.format {
+	case "env":
+		if err := writeEnv(out, values); err != nil {
+			return err
+		}
+		if split {
+			return writeFile(opts.secretsOut, secrets, writeEnv)
+		}
+
+	case "json":
+		if err := writeJSON(out, values); err != nil {
+			return err
+		}
+		if split {
+			return writeFile(opts.secretsOut, secrets, writeJSON)
+		}
+
+	case "configmap":
+		return writeConfigMap(out, opts.name, values, secrets, split)
+
+	default:
+		return fmt.Errorf("unknown format %q, expected env, json or configmap", opts.format)
+	}
+
+	return nil
+}
+
+func writeFile(filename string, settings []gocore.ExportedSetting, write func(io.Writer, []gocore.ExportedSetting) error) error {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return write(f, settings)
+}
+
+// envValue quotes value for a .env file if it contains anything that would
+// otherwise be misread.
+func envValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\r\n#\"'\\$=") {
+		return value
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func writeEnv(w io.Writer, settings []gocore.ExportedSetting) error {
+	bw := bufio.NewWriter(w)
+
+	for _, s := range settings {
+		fmt.Fprintf(bw, "%s=%s\n", s.Key, envValue(s.Value))
+	}
+
+	return bw.Flush()
+}
+
+func writeJSON(w io.Writer, settings []gocore.ExportedSetting) error {
+	m := make(map[string]string, len(settings))
+	for _, s := range settings {
+		m[s.Key] = s.Value
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(m)
+}
+
+// yamlString quotes s as a YAML double quoted scalar, which accepts the same
+// escapes as JSON.
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func writeYAMLData(bw *bufio.Writer, field string, settings []gocore.ExportedSetting) {
+	fmt.Fprintf(bw, "%s:\n", field)
+
+	for _, s := range settings {
+		if !reConfigMapKey.MatchString(s.Key) {
+			fmt.Fprintf(os.Stderr, "WARN: skipping %q, which is not a valid ConfigMap key\n", s.Key)
+			continue
+		}
+		fmt.Fprintf(bw, "  %s: %s\n", s.Key, yamlString(s.Value))
+	}
+}
+
+func writeConfigMap(w io.Writer, name string, values, secrets []gocore.ExportedSetting, split bool) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n", yamlString(name))
+	writeYAMLData(bw, "data", values)
+
+	if split {
+		fmt.Fprintf(bw, "---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: %s\ntype: Opaque\n", yamlString(name+"-secrets"))
+		writeYAMLData(bw, "stringData", secrets)
+	}
+
+	return bw.Flush()
+}
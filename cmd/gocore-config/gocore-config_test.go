@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ordishs/gocore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvValue(t *testing.T) {
+	assert.Equal(t, "plain", envValue("plain"))
+	assert.Equal(t, `""`, envValue(""))
+	assert.Equal(t, `"two words"`, envValue("two words"))
+	assert.Equal(t, `"say \"hi\"\n"`, envValue("say \"hi\"\n"))
+}
+
+func TestExportFlagsDefaultFiles(t *testing.T) {
+	var (
+		opts                        exportOptions
+		context, app, files, output string
+	)
+
+	require.NoError(t, exportFlags(&opts, &context, &app, &files, &output).Parse(nil))
+	assert.Equal(t, gocore.SettingsFiles(), strings.Split(files, ","))
+}
+
+func TestWriteConfigMap(t *testing.T) {
+	values := []gocore.ExportedSetting{{Key: "url", Value: "https://example.com"}, {Key: "peer[0]", Value: "x"}}
+	secrets := []gocore.ExportedSetting{{Key: "token", Value: `a"b`, Secret: true}}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeConfigMap(buf, "foo", values, secrets, true))
+
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "foo"
+data:
+  url: "https://example.com"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: "foo-secrets"
+type: Opaque
+stringData:
+  token: "a\"b"
+`, buf.String())
+}
+
+func TestExportSplit(t *testing.T) {
+	dir := t.TempDir()
+
+	settings := filepath.Join(dir, "settings.conf")
+	require.NoError(t, os.WriteFile(settings, []byte(`
+url      = http://localhost
+url.live = https://example.com
+token    = *EHE*375dc2abb491dd879215a8b3d8d8fce52e6b6357c9c845e2e0a482e45eb69c43d711
+`), 0600))
+
+	output := filepath.Join(dir, "settings.json")
+	secretsOut := filepath.Join(dir, "secrets.json")
+
+	opts := exportOptions{format: "json", secrets: "split", secretsOut: secretsOut}
+	require.NoError(t, export(opts, "live", "", []string{settings, filepath.Join(dir, "missing.conf")}, output))
+
+	var values, secrets map[string]string
+
+	b, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &values))
+	assert.Equal(t, map[string]string{"url": "https://example.com"}, values)
+
+	b, err = os.ReadFile(secretsOut)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &secrets))
+	assert.Contains(t, secrets, "token")
+	assert.NotContains(t, secrets["token"], "*EHE*")
+
+	opts = exportOptions{format: "env", secrets: "encrypted", envPrefix: "APP_"}
+	require.NoError(t, export(opts, "live", "", []string{settings}, output))
+
+	b, err = os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "APP_URL=https://example.com\n")
+	assert.Contains(t, string(b), "APP_TOKEN=*EHE*375dc2")
+
+	assert.Error(t, export(exportOptions{format: "env", secrets: "split"}, "live", "", []string{settings}, output))
+	assert.Error(t, export(exportOptions{format: "yaml", secrets: "encrypted"}, "live", "", []string{settings}, output))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ordishs/gocore"
+)
+
+const usage = `Usage:
+  gocore-diff [flags] <context> <otherContext>
+      Compare two contexts using the settings files in the current directory.
+
+  gocore-diff [flags] -from <rev> [-to <rev>] <context> [otherContext]
+      Compare the settings files at two git revisions.  Without -to, the
+      files in the working tree are used.
+
+Flags:
+`
+
+func main() {
+	var (
+		help  bool
+		app   string
+		files string
+		from  string
+		to    string
+	)
+
+	flag.BoolVar(&help, "h", false, "Help")
+	flag.StringVar(&app, "app", "", "Application name used when resolving values")
+	flag.StringVar(&files, "files", strings.Join(gocore.SettingsFiles(), ","), "Comma separated settings files, in the order they are loaded")
+	flag.StringVar(&from, "from", "", "Git revision of the settings files for the first context")
+	flag.StringVar(&to, "to", "", "Git revision of the settings files for the second context (default working tree)")
+	flag.Parse()
+
+	args := flag.Args()
+
+	if help || len(args) == 0 || len(args) > 2 || (len(args) == 1 && from == "" && to == "") {
+		fmt.Print(usage)
+		flag.PrintDefaults()
+		return
+	}
+
+	context := args[0]
+	otherContext := context
+	if len(args) == 2 {
+		otherContext = args[1]
+	}
+
+	differ, err := diff(os.Stdout, context, otherContext, app, from, to, strings.Split(files, ","))
+	if err != nil {
+		fmt.Println("Error loading settings:", err)
+		os.Exit(1)
+	}
+
+	if differ {
+		os.Exit(1)
+	}
+}
+
+// diff writes the differences between context at revision from and
+// otherContext at revision to, and reports whether there were any.
+func diff(w io.Writer, context, otherContext, app, from, to string, filenames []string) (bool, error) {
+	cfg, err := load(context, app, from, filenames)
+	if err != nil {
+		return false, err
+	}
+
+	other, err := load(otherContext, app, to, filenames)
+	if err != nil {
+		return false, err
+	}
+
+	diffs := cfg.DiffWith(other)
+
+	if _, err := fmt.Fprint(w, gocore.FormatDiff(diffs, label(context, from), label(otherContext, to))); err != nil {
+		return false, err
+	}
+
+	return len(diffs) > 0, nil
+}
+
+func label(context, rev string) string {
+	if rev == "" {
+		return context
+	}
+
+	return context + "@" + rev
+}
+
+// load reads the settings files, from the working tree or from a git
+// revision, into a standalone configuration.  Files that do not exist are
+// skipped, as settings_local.conf usually isn't committed.
+func load(context, app, rev string, filenames []string) (*gocore.Configuration, error) {
+	cfg := gocore.NewConfiguration(context, app)
+
+	for _, filename := range filenames {
+		filename = strings.TrimSpace(filename)
+		if filename == "" {
+			continue
+		}
+
+		if rev == "" {
+			if err := cfg.LoadSettingsFile(filename); err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			continue
+		}
+
+		content, found, err := gitShow(rev, filename)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			if err := cfg.AddSettings(rev+":"+filename, content); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// gitShow returns the contents of filename, relative to the current
+// directory, at the given revision.
+func gitShow(rev, filename string) ([]byte, bool, error) {
+	if err := exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}").Run(); err != nil {
+		return nil, false, fmt.Errorf("unknown git revision %q", rev)
+	}
+
+	out, err := exec.Command("git", "show", rev+":./"+filename).Output()
+	if err != nil {
+		// The file does not exist at this revision
+		return nil, false, nil
+	}
+
+	return out, true, nil
+}
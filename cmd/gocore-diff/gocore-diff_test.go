@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inDir runs the test in a new temporary directory, as gocore-diff reads the
+// settings files from the current directory.
+func inDir(t *testing.T) string {
+	dir := t.TempDir()
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	return dir
+}
+
+func TestDiffContexts(t *testing.T) {
+	dir := inDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "settings.conf"), []byte("url = a\nurl.live = b\nport = 1\n"), 0600))
+
+	buf := &bytes.Buffer{}
+	differ, err := diff(buf, "dev", "live", "", "", "", []string{"settings.conf", "settings_local.conf"})
+	require.NoError(t, err)
+	assert.True(t, differ)
+	assert.Equal(t, `KEY  DEV                  LIVE                 CAUSE
+url  a [settings.conf:1]  b [settings.conf:2]  file
+`, buf.String())
+
+	buf.Reset()
+	differ, err = diff(buf, "live", "live", "", "", "", []string{"settings.conf"})
+	require.NoError(t, err)
+	assert.False(t, differ)
+}
+
+func TestDiffRevisions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := inDir(t)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "settings.conf"), []byte("url = a\n"), 0600))
+	git("init", "-q")
+	git("add", "settings.conf")
+	git("commit", "-q", "-m", "settings")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "settings.conf"), []byte("url = b\n"), 0600))
+
+	buf := &bytes.Buffer{}
+	differ, err := diff(buf, "dev", "dev", "", "HEAD", "", []string{"settings.conf"})
+	require.NoError(t, err)
+	assert.True(t, differ)
+	assert.Equal(t, `KEY  DEV@HEAD                  DEV                  CAUSE
+url  a [HEAD:settings.conf:1]  b [settings.conf:1]  file
+`, buf.String())
+
+	_, err = diff(buf, "dev", "dev", "", "nosuchrevision", "", []string{"settings.conf"})
+	assert.EqualError(t, err, `unknown git revision "nosuchrevision"`)
+}
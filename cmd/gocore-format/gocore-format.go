@@ -9,6 +9,8 @@ import (
 	"sort"
 	"strings"
 	"unicode"
+
+	"github.com/ordishs/gocore/parser"
 )
 
 type Setting struct {
@@ -24,25 +26,74 @@ type Setting struct {
 type Variant struct {
 	Commented bool
 	Key       string
-	Value     string
+	Value     string // The value as written, including any quotes
+	Resolved  string // The value with quotes, escapes and heredocs resolved
 	Comment   string // The comment after the key=value pair
 }
 
+// renameFlags collects repeated -rename old=new flags.
+type renameFlags map[string]string
+
+func (r renameFlags) String() string {
+	pairs := make([]string, 0, len(r))
+	for oldKey, newKey := range r {
+		pairs = append(pairs, oldKey+"="+newKey)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (r renameFlags) Set(value string) error {
+	oldKey, newKey, ok := strings.Cut(value, "=")
+	oldKey = strings.TrimSpace(oldKey)
+	newKey = strings.TrimSpace(newKey)
+
+	if !ok || oldKey == "" || newKey == "" {
+		return fmt.Errorf("expected old=new, got %q", value)
+	}
+
+	r[oldKey] = newKey
+
+	return nil
+}
+
 func main() {
 	var (
-		write    bool
-		help     bool
-		filename string
-		in       = os.Stdin
-		err      error
+		write        bool
+		help         bool
+		matrix       bool
+		matrixFormat string
+		contexts     string
+		app          string
+		filename     string
+		renames      = renameFlags{}
+		in           = os.Stdin
+		err          error
 	)
 
 	flag.BoolVar(&write, "w", false, "Write to file")
 	flag.BoolVar(&help, "h", false, "Help")
+	flag.Var(renames, "rename", "Rewrite a deprecated key to its new name, e.g. -rename old_key=new_key (repeatable)")
+	flag.BoolVar(&matrix, "matrix", false, "Print a key by context table of effective values instead of formatting")
+	flag.StringVar(&matrixFormat, "format", "md", "Matrix format: md, csv or html.  Inherited values are italic, or marked with ↑ in CSV")
+	flag.StringVar(&contexts, "contexts", "", "Comma separated contexts for the matrix columns (default all contexts in the file)")
+	flag.StringVar(&app, "app", "", "Application name used when resolving matrix values")
+
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			fmt.Println("Error importing settings:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	flag.Parse()
 
 	if help {
 		flag.PrintDefaults()
+		fmt.Fprintln(flag.CommandLine.Output())
+		printImportUsage(flag.CommandLine.Output())
 		return
 	}
 
@@ -65,25 +116,30 @@ func main() {
 		return
 	}
 
-	sortSettings(settings)
+	settings = renameSettings(settings, renames)
 
-	if filename != "" && write {
-		in.Close()
+	sortSettings(settings)
 
-		out, err := os.Create(filename + ".tmp")
-		if err != nil {
-			fmt.Println("Error creating output file:", err)
-			return
+	if matrix {
+		columns := matrixContexts(settings)
+		if contexts != "" {
+			columns = strings.Split(contexts, ",")
+			for i := range columns {
+				columns[i] = strings.TrimSpace(columns[i])
+			}
 		}
-		defer out.Close()
 
-		if err := writeSettings(out, settings); err != nil {
-			fmt.Println("Error writing file:", err)
-			return
+		if err := writeMatrix(os.Stdout, buildMatrix(settings, columns, app), matrixFormat); err != nil {
+			fmt.Println("Error writing matrix:", err)
 		}
+		return
+	}
+
+	if filename != "" && write {
+		in.Close()
 
-		if err := os.Rename(filename+".tmp", filename); err != nil {
-			fmt.Println("Error renaming file:", err)
+		if err := writeSettingsFile(filename, settings); err != nil {
+			fmt.Println("Error writing file:", err)
 			return
 		}
 	} else {
@@ -102,28 +158,29 @@ func readSettings(r io.Reader) ([]*Setting, error) {
 
 	settings := make(map[string]*Setting)
 
-	scanner := bufio.NewScanner(r)
-
-	for scanner.Scan() {
-		line := scanner.Text()
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
 
-		line = strings.TrimSpace(line)
+	entries, err := parser.Parse("", src)
+	if err != nil {
+		// Rewriting a file with syntax errors would drop the broken lines
+		return nil, err
+	}
 
-		if line == "" {
-			continue
-		}
+	for _, entry := range entries {
+		line := entry.Comment
 
-		if strings.HasPrefix(line, "# @group:") {
-			currentGroup = strings.TrimSpace(strings.TrimPrefix(line, "# @group:"))
-			isCompactGroup = strings.HasSuffix(currentGroup, " compact")
+		if name, compact, ok := parser.GroupStart(line); ok && entry.Kind == parser.Comment {
+			currentGroup, isCompactGroup = name, compact
 			if isCompactGroup {
-				currentGroup = strings.TrimSuffix(currentGroup, " compact")
 				maxKeyLength = 0 // Reset max key length for new compact group
 			}
 			continue
 		}
 
-		if line == "# @endgroup" {
+		if entry.Kind == parser.Comment && parser.IsGroupEnd(line) {
 			if isCompactGroup {
 				// Store the max key length in the settings for the compact group
 				for _, setting := range settings {
@@ -138,7 +195,20 @@ func readSettings(r io.Reader) ([]*Setting, error) {
 			continue
 		}
 
-		item := processLine(line)
+		var item *Variant
+
+		if entry.Kind == parser.Setting {
+			// Keep the value as written so that quotes, escapes and heredocs
+			// survive formatting
+			item = &Variant{
+				Key:      cleanKey(entry.Key),
+				Value:    entry.Raw,
+				Resolved: entry.Value,
+				Comment:  entry.Comment,
+			}
+		} else {
+			item = processLine(line)
+		}
 
 		if item == nil {
 			// This is an arbitrary comment line
@@ -186,10 +256,6 @@ func readSettings(r io.Reader) ([]*Setting, error) {
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
 	settingsSlice := make([]*Setting, 0, len(settings))
 	for _, setting := range settings {
 		settingsSlice = append(settingsSlice, setting)
@@ -278,7 +344,15 @@ func writeSettings(w io.Writer, settings []*Setting) error {
 				length -= 2
 			}
 
-			value := cleanMultiValues(variant.Value)
+			value := variant.Value
+			if !strings.ContainsAny(value, "\n\"'\\") {
+				value = cleanMultiValues(value)
+			}
+
+			// color=#fff is a value, but color = #fff would be a comment
+			if strings.HasPrefix(value, "#") {
+				value = `\` + value
+			}
 
 			var line strings.Builder
 
@@ -345,6 +419,73 @@ func processLine(line string) *Variant {
 	return setting
 }
 
+// renameSettings rewrites every variant of each old root key to the new root
+// key, keeping the context suffixes.  If the new key already exists, the
+// variants are merged into it, and where both have the same variant the new
+// key's one is kept.
+func renameSettings(settings []*Setting, renames map[string]string) []*Setting {
+	if len(renames) == 0 {
+		return settings
+	}
+
+	byKey := make(map[string]*Setting, len(settings))
+	for _, setting := range settings {
+		byKey[setting.Key] = setting
+	}
+
+	result := make([]*Setting, 0, len(settings))
+
+	for _, setting := range settings {
+		newKey, found := renames[setting.Key]
+		if !found {
+			result = append(result, setting)
+			continue
+		}
+
+		for i := range setting.Variants {
+			setting.Variants[i].Key = newKey + strings.TrimPrefix(setting.Variants[i].Key, setting.Key)
+		}
+
+		if existing, found := byKey[newKey]; found && existing != setting {
+			existing.Variants = mergeVariants(existing.Variants, setting.Variants)
+			if existing.Comments == "" {
+				existing.Comments = setting.Comments
+			}
+			continue
+		}
+
+		if setting.SortBy == setting.Key {
+			setting.SortBy = newKey
+		}
+		setting.Key = newKey
+		byKey[newKey] = setting
+
+		result = append(result, setting)
+	}
+
+	return result
+}
+
+// mergeVariants appends the variants in from that are not already in to,
+// where a commented variant and a setting with the same key are different.
+func mergeVariants(to, from []Variant) []Variant {
+	for _, v := range from {
+		duplicate := false
+		for _, existing := range to {
+			if existing.Key == v.Key && existing.Commented == v.Commented {
+				duplicate = true
+				break
+			}
+		}
+
+		if !duplicate {
+			to = append(to, v)
+		}
+	}
+
+	return to
+}
+
 func cleanKey(key string) string {
 	parts := strings.Split(strings.TrimSpace(key), ".")
 
@@ -168,3 +168,119 @@ func TestProcessLine(t *testing.T) {
 		})
 	}
 }
+
+func TestRenameSettings(t *testing.T) {
+	reader := strings.NewReader(`
+		# Old host
+		db_host=localhost
+		db_host.live=db.live
+		database_port=5432
+		db_port.live=6543
+	`)
+
+	settings, err := readSettings(reader)
+	require.NoError(t, err)
+
+	settings = renameSettings(settings, map[string]string{
+		"db_host": "database_host",
+		"db_port": "database_port",
+	})
+
+	sortSettings(settings)
+
+	buf := &bytes.Buffer{}
+	err = writeSettings(buf, settings)
+	require.NoError(t, err)
+
+	assert.Equal(t, `# Old host
+database_host      = localhost
+database_host.live = db.live
+
+database_port      = 5432
+database_port.live = 6543
+`, buf.String())
+}
+
+func TestRenameSettingsDuplicates(t *testing.T) {
+	reader := strings.NewReader(`
+		db_host=localhost
+		db_host.live=old.live
+		#db_host.dev=old.dev
+		database_host=db
+		database_host.live=new.live
+	`)
+
+	settings, err := readSettings(reader)
+	require.NoError(t, err)
+
+	settings = renameSettings(settings, map[string]string{"db_host": "database_host"})
+
+	sortSettings(settings)
+
+	buf := &bytes.Buffer{}
+	err = writeSettings(buf, settings)
+	require.NoError(t, err)
+
+	assert.Equal(t, `database_host       = db
+database_host.live  = new.live
+# database_host.dev = old.dev
+`, buf.String())
+}
+
+func TestQuotedValues(t *testing.T) {
+	reader := strings.NewReader(`
+		color=#fff
+		title = "a # b|c" # quoted
+		list = a|b|c
+		hash = \#fff
+		path = "first, \
+		       second"
+		pem = <<EOF
+-----BEGIN KEY-----
+  a|b # c
+-----END KEY-----
+EOF
+	`)
+
+	settings, err := readSettings(reader)
+	require.NoError(t, err)
+
+	sortSettings(settings)
+
+	buf := &bytes.Buffer{}
+	err = writeSettings(buf, settings)
+	require.NoError(t, err)
+
+	assert.Equal(t, `color = \#fff
+
+hash = \#fff
+
+list = a | b | c
+
+path = "first, \
+		       second"
+
+pem = <<EOF
+-----BEGIN KEY-----
+  a|b # c
+-----END KEY-----
+EOF
+
+title = "a # b|c" # quoted
+`, buf.String())
+
+	// Formatting again must not change anything
+	again, err := readSettings(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	sortSettings(again)
+
+	buf2 := &bytes.Buffer{}
+	require.NoError(t, writeSettings(buf2, again))
+	assert.Equal(t, buf.String(), buf2.String())
+}
+
+func TestSyntaxError(t *testing.T) {
+	_, err := readSettings(strings.NewReader("a = \"unterminated\n"))
+	assert.EqualError(t, err, `:1: a: unterminated " quoted value`)
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/ordishs/gocore/utils"
+)
+
+const importUsage = `Usage:
+  gocore-format import [flags] [settings.conf]
+      Convert a .env file, or the environment variables with a prefix, into
+      settings for a context and merge them into settings.conf.  The merged
+      file is printed unless -w is given.
+
+Import flags:
+`
+
+const defaultSecretPatterns = "*password*,*passwd*,*secret*,*token*,*apikey*,*api_key*,*private_key*,*credentials*"
+
+type importOptions struct {
+	envFile        string
+	prefix         string
+	context        string
+	group          string
+	encrypt        bool
+	secretPatterns string
+	write          bool
+}
+
+// ImportedSetting is a key and value converted from an environment variable.
+type ImportedSetting struct {
+	Key   string
+	Value string
+}
+
+func importFlags(opts *importOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+
+	fs.StringVar(&opts.envFile, "env", "", "The .env file to import (default the current environment, which needs -prefix)")
+	fs.StringVar(&opts.prefix, "prefix", "", "Only import variables with this prefix, as read with SETTINGS_ENV_PREFIX.  The prefix is removed before names are mapped to keys")
+	fs.StringVar(&opts.context, "context", "", "Context the values are for, e.g. live adds them as key.live (default the base key)")
+	fs.StringVar(&opts.group, "group", "", "Put new keys in this @group")
+	fs.BoolVar(&opts.encrypt, "encrypt", false, "Encrypt the values of keys that match -secret-patterns")
+	fs.StringVar(&opts.secretPatterns, "secret-patterns", defaultSecretPatterns, "Comma separated path.Match patterns for secret keys, matched case insensitively")
+	fs.BoolVar(&opts.write, "w", false, "Write to file")
+
+	return fs
+}
+
+func runImport(args []string) error {
+	var opts importOptions
+
+	fs := importFlags(&opts)
+	fs.Usage = func() {
+		printImportUsage(fs.Output())
+	}
+	_ = fs.Parse(args)
+
+	filename := "settings.conf"
+	if fs.NArg() > 0 {
+		filename = fs.Arg(0)
+	}
+
+	vars, err := importSource(opts.envFile, opts.prefix)
+	if err != nil {
+		return err
+	}
+
+	var settings []*Setting
+
+	in, err := os.Open(filename)
+	switch {
+	case err == nil:
+		settings, err = readSettings(in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	imported, err := convertEnv(vars, opts, contextNames(settings, opts.context))
+	if err != nil {
+		return err
+	}
+
+	settings = mergeSettings(settings, imported, opts.group)
+
+	sortSettings(settings)
+
+	if opts.write {
+		return writeSettingsFile(filename, settings)
+	}
+
+	return writeSettings(os.Stdout, settings)
+}
+
+// importSource returns the variables in envFile, or in the environment if
+// envFile is empty, whose names start with prefix.
+func importSource(envFile, prefix string) (map[string]string, error) {
+	var vars map[string]string
+
+	if envFile != "" {
+		var err error
+		if vars, err = godotenv.Read(envFile); err != nil {
+			return nil, err
+		}
+	} else {
+		if prefix == "" {
+			return nil, fmt.Errorf("importing from the environment needs -prefix")
+		}
+
+		vars = make(map[string]string)
+		for _, kv := range os.Environ() {
+			if name, value, ok := strings.Cut(kv, "="); ok {
+				vars[name] = value
+			}
+		}
+	}
+
+	filtered := make(map[string]string, len(vars))
+	for name, value := range vars {
+		if strings.HasPrefix(name, prefix) && name != prefix {
+			filtered[name] = value
+		}
+	}
+
+	return filtered, nil
+}
+
+// convertEnv turns environment variables into settings for opts.context,
+// sorted by key.  Names are mapped back to keys the way SETTINGS_ENV_PREFIX
+// maps keys to names, after removing any prefix, so APP_DB__HOST becomes
+// db.host and DB_HOST becomes db_host.  With a context, a name that already
+// ends in one of contexts, such as APP_URL__LIVE, is an error, as it would
+// be imported as url.live.live.  So is a value containing ${, which would be
+// read back as a reference to another setting.
+func convertEnv(vars map[string]string, opts importOptions, contexts map[string]bool) ([]ImportedSetting, error) {
+	patterns := splitList(opts.secretPatterns)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid secret pattern %q: %w", pattern, err)
+		}
+	}
+
+	imported := make([]ImportedSetting, 0, len(vars))
+
+	for name, value := range vars {
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, opts.prefix), "__", "."))
+
+		if strings.Contains(value, "${") {
+			return nil, fmt.Errorf("the value of %s contains ${, which would be read as a reference to another setting", name)
+		}
+
+		if opts.context != "" {
+			if i := strings.LastIndex(key, "."); i >= 0 && contexts[key[i+1:]] {
+				return nil, fmt.Errorf("%s already has the context suffix %q, which cannot be combined with -context", name, key[i+1:])
+			}
+		}
+
+		if opts.encrypt && isSecretKey(key, patterns) {
+			var err error
+			if value, err = utils.EncryptSetting(value); err != nil {
+				return nil, fmt.Errorf("encrypting %s: %w", key, err)
+			}
+		}
+
+		if opts.context != "" {
+			key += "." + opts.context
+		}
+
+		imported = append(imported, ImportedSetting{Key: key, Value: value})
+	}
+
+	sort.Slice(imported, func(i, j int) bool {
+		return imported[i].Key < imported[j].Key
+	})
+
+	return imported, nil
+}
+
+// contextNames returns the parts of context and the names that are used as
+// a context suffix in settings, which are those after the root key in the
+// variants of at least two keys.
+func contextNames(settings []*Setting, context string) map[string]bool {
+	names := make(map[string]bool)
+	if context != "" {
+		for _, part := range strings.Split(context, ".") {
+			names[part] = true
+		}
+	}
+
+	used := make(map[string]int)
+	for _, setting := range settings {
+		seen := make(map[string]bool)
+		for _, v := range setting.Variants {
+			parts := strings.Split(v.Key, ".")
+			for _, part := range parts[1:] {
+				if !seen[part] {
+					seen[part] = true
+					used[part]++
+				}
+			}
+		}
+	}
+
+	for name, n := range used {
+		if n >= 2 {
+			names[name] = true
+		}
+	}
+
+	return names
+}
+
+func isSecretKey(key string, patterns []string) bool {
+	key = strings.ToLower(key)
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitList(s string) []string {
+	var items []string
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// mergeSettings adds the imported values to settings.  A variant with the same
+// key is replaced, but the other contexts' variants of a key are left alone.
+// Keys that are not in settings yet are added to group, if it is set.
+func mergeSettings(settings []*Setting, imported []ImportedSetting, group string) []*Setting {
+	byKey := make(map[string]*Setting, len(settings))
+	for _, setting := range settings {
+		byKey[setting.Key] = setting
+	}
+
+	compact := false
+	for _, setting := range settings {
+		if group != "" && setting.Group == group && setting.Compact {
+			compact = true
+		}
+	}
+
+	for _, imp := range imported {
+		rootKey := strings.Split(imp.Key, ".")[0]
+
+		variant := Variant{Key: imp.Key, Value: quoteValue(imp.Value), Resolved: imp.Value}
+
+		setting, found := byKey[rootKey]
+		if !found {
+			setting = &Setting{Key: rootKey, SortBy: rootKey}
+			if group != "" {
+				setting.Group = group
+				setting.SortBy = group
+				setting.Compact = compact
+			}
+			byKey[rootKey] = setting
+			settings = append(settings, setting)
+		}
+
+		replaced := false
+		for i := range setting.Variants {
+			if !setting.Variants[i].Commented && setting.Variants[i].Key == imp.Key {
+				variant.Comment = setting.Variants[i].Comment
+				setting.Variants[i] = variant
+				replaced = true
+			}
+		}
+
+		if replaced {
+			continue
+		}
+
+		if imp.Key == rootKey {
+			// The default value goes before its context variants
+			setting.Variants = append([]Variant{variant}, setting.Variants...)
+		} else {
+			setting.Variants = append(setting.Variants, variant)
+		}
+	}
+
+	alignCompactGroups(settings)
+
+	return settings
+}
+
+// alignCompactGroups sets the key width of each compact group to fit all of
+// its keys.
+func alignCompactGroups(settings []*Setting) {
+	widths := make(map[string]int)
+
+	for _, setting := range settings {
+		if !setting.Compact {
+			continue
+		}
+		for _, variant := range setting.Variants {
+			l := len(variant.Key)
+			if variant.Commented {
+				l += 2
+			}
+			if l > widths[setting.Group] {
+				widths[setting.Group] = l
+			}
+		}
+	}
+
+	for _, setting := range settings {
+		if setting.Compact {
+			setting.MaxKeyLength = widths[setting.Group]
+		}
+	}
+}
+
+// quoteValue returns value as it must be written in a settings file to be
+// read back unchanged.  Values that would be trimmed, split into a comment or
+// reformatted as a multi-value list are double quoted.  Settings files have no
+// way to write ${ without it being read as a reference, so convertEnv rejects
+// such values before they get here.
+func quoteValue(value string) string {
+	if value == "" {
+		return value
+	}
+
+	plain := strings.TrimSpace(value) == value &&
+		!strings.ContainsAny(value, "\"'\\|\n\r\t") &&
+		!strings.Contains(value, " #") &&
+		!strings.HasPrefix(value, "<<")
+
+	if plain {
+		return value
+	}
+
+	var b strings.Builder
+
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// writeSettingsFile replaces filename with the formatted settings.
+func writeSettingsFile(filename string, settings []*Setting) error {
+	out, err := os.Create(filename + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	if err := writeSettings(out, settings); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(filename+".tmp", filename)
+}
+
+// printImportUsage describes the import subcommand and its flags.
+func printImportUsage(w io.Writer) {
+	fmt.Fprint(w, importUsage)
+	fs := importFlags(&importOptions{})
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ordishs/gocore/parser"
+	"github.com/ordishs/gocore/utils"
+)
+
+func TestConvertEnv(t *testing.T) {
+	vars := map[string]string{
+		"APP_URL":             "https://example.com",
+		"APP_DB__HOST":        "db",
+		"APP_DB_PASSWORD":     "hunter2",
+		"APP_STRIPE_API_KEY":  "sk_live",
+		"APP_STRIPE_ACCOUNTS": "1",
+	}
+
+	imported, err := convertEnv(vars, importOptions{prefix: "APP_", context: "live", encrypt: true, secretPatterns: defaultSecretPatterns}, nil)
+	require.NoError(t, err)
+
+	keys := make([]string, len(imported))
+	values := make(map[string]string)
+	for i, imp := range imported {
+		keys[i] = imp.Key
+		values[imp.Key] = imp.Value
+	}
+
+	assert.Equal(t, []string{"db.host.live", "db_password.live", "stripe_accounts.live", "stripe_api_key.live", "url.live"}, keys)
+	assert.Equal(t, "https://example.com", values["url.live"])
+
+	for _, key := range []string{"db_password.live", "stripe_api_key.live"} {
+		assert.True(t, strings.HasPrefix(values[key], "*EHE*"), key)
+	}
+
+	decrypted, err := utils.DecryptSetting(values["db_password.live"])
+	require.NoError(t, err)
+	assert.Equal(t, "*EHE*hunter2", decrypted)
+
+	// Without a prefix, names are mapped the same way
+	imported, err = convertEnv(map[string]string{"DB_HOST": "x", "KAFKA__BROKERS": "y"}, importOptions{secretPatterns: defaultSecretPatterns}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []ImportedSetting{{Key: "db_host", Value: "x"}, {Key: "kafka.brokers", Value: "y"}}, imported)
+
+	_, err = convertEnv(vars, importOptions{secretPatterns: "[x"}, nil)
+	assert.Error(t, err)
+
+	// A name with a context suffix cannot be imported for a context
+	contexts := contextNames([]*Setting{
+		{Key: "url", Variants: []Variant{{Key: "url"}, {Key: "url.stage"}}},
+		{Key: "port", Variants: []Variant{{Key: "port.stage"}}},
+		{Key: "db", Variants: []Variant{{Key: "db.host"}}},
+	}, "live")
+	assert.Equal(t, map[string]bool{"live": true, "stage": true}, contexts)
+
+	_, err = convertEnv(map[string]string{"APP_URL__LIVE": "x"}, importOptions{prefix: "APP_", context: "live"}, contexts)
+	assert.EqualError(t, err, `APP_URL__LIVE already has the context suffix "live", which cannot be combined with -context`)
+
+	_, err = convertEnv(map[string]string{"APP_URL__STAGE": "x"}, importOptions{prefix: "APP_", context: "live"}, contexts)
+	assert.Error(t, err)
+
+	imported, err = convertEnv(map[string]string{"APP_URL__LIVE": "x"}, importOptions{prefix: "APP_"}, contexts)
+	require.NoError(t, err)
+	assert.Equal(t, []ImportedSetting{{Key: "url.live", Value: "x"}}, imported)
+
+	// ${ cannot be written to settings.conf without being interpolated
+	_, err = convertEnv(map[string]string{"APP_PATH": "${HOME}/bin"}, importOptions{prefix: "APP_"}, nil)
+	assert.EqualError(t, err, "the value of APP_PATH contains ${, which would be read as a reference to another setting")
+}
+
+func TestImportSource(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(envFile, []byte("APP_A=1\nOTHER=2\nexport APP_B=\"two words\"\n"), 0600))
+
+	vars, err := importSource(envFile, "APP_")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"APP_A": "1", "APP_B": "two words"}, vars)
+
+	t.Setenv("GOCORE_IMPORT_TEST_X", "y")
+
+	vars, err = importSource("", "GOCORE_IMPORT_TEST_")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"GOCORE_IMPORT_TEST_X": "y"}, vars)
+
+	_, err = importSource("", "")
+	assert.Error(t, err)
+}
+
+func TestMergeSettings(t *testing.T) {
+	settings, err := readSettings(strings.NewReader(`
+url = http://localhost # The public URL
+url.stage = https://stage.example.com
+url.live = https://old.example.com
+
+# @group: Database compact
+db_host = localhost
+db_port = 5432
+# @endgroup
+`))
+	require.NoError(t, err)
+
+	imported := []ImportedSetting{
+		{Key: "url.live", Value: "https://example.com"},
+		{Key: "db_user.live", Value: "app"},
+		{Key: "motd.live", Value: "hello # world"},
+		{Key: "timeout", Value: "5s"},
+	}
+
+	settings = mergeSettings(settings, imported, "Database")
+	sortSettings(settings)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeSettings(buf, settings))
+
+	assert.Equal(t, `# @group: Database compact
+db_host      = localhost
+db_port      = 5432
+db_user.live = app
+motd.live    = "hello # world"
+timeout      = 5s
+# @endgroup
+
+url       = http://localhost # The public URL
+url.stage = https://stage.example.com
+url.live  = https://example.com
+`, buf.String())
+}
+
+func TestQuoteValue(t *testing.T) {
+	for _, value := range []string{
+		"plain",
+		"",
+		" padded ",
+		"a # b",
+		"#fff",
+		`say "hi"`,
+		"it's",
+		`C:\path`,
+		"a|b",
+		"line1\nline2\ttab",
+		"<<EOF",
+	} {
+		settings := mergeSettings(nil, []ImportedSetting{{Key: "k", Value: value}}, "")
+
+		buf := &bytes.Buffer{}
+		require.NoError(t, writeSettings(buf, settings))
+
+		entries, err := parser.Parse("", buf.Bytes())
+		require.NoError(t, err, buf.String())
+		require.Len(t, entries, 1)
+		assert.Equal(t, value, entries[0].Value, buf.String())
+	}
+}
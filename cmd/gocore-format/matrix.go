@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+)
+
+const secretMask = "********************"
+
+// MatrixCell is the effective value of a key in one context.
+type MatrixCell struct {
+	Value string
+	// From is the key the value was found under.  It is empty if there is
+	// no value for the context.
+	From string
+	// Inherited is true if the value came from a parent context, e.g. from
+	// url.live for the live.uk context.
+	Inherited bool
+}
+
+// Matrix is a key by context table of effective values.
+type Matrix struct {
+	Contexts []string
+	Keys     []string
+	Cells    map[string][]MatrixCell
+}
+
+// matrixContexts returns every context suffix used in the settings, such as
+// live and live.uk, preceded by "" for the default value.
+func matrixContexts(settings []*Setting) []string {
+	seen := make(map[string]struct{})
+
+	for _, setting := range settings {
+		for _, variant := range setting.Variants {
+			if variant.Commented {
+				continue
+			}
+			if suffix := strings.TrimPrefix(variant.Key, setting.Key+"."); suffix != variant.Key {
+				seen[suffix] = struct{}{}
+			}
+		}
+	}
+
+	contexts := make([]string, 0, len(seen)+1)
+	for ctx := range seen {
+		contexts = append(contexts, ctx)
+	}
+	sort.Strings(contexts)
+
+	return append([]string{""}, contexts...)
+}
+
+// candidateKeys lists the keys that are tried, most specific first, when key
+// is requested in the given context and app.  It mirrors the fallback used by
+// gocore's findValue.
+func candidateKeys(key, context, app string) []string {
+	k := key
+	if context != "" {
+		k += "." + context
+	}
+
+	var keys []string
+
+	for {
+		if app != "" {
+			keys = append(keys, k+"."+app)
+		}
+		keys = append(keys, k)
+
+		pos := strings.LastIndex(k, ".")
+		if pos == -1 || len(k[:pos]) < len(key) {
+			break
+		}
+		k = k[:pos]
+	}
+
+	return keys
+}
+
+func buildMatrix(settings []*Setting, contexts []string, app string) *Matrix {
+	m := &Matrix{
+		Contexts: contexts,
+		Cells:    make(map[string][]MatrixCell),
+	}
+
+	for _, setting := range settings {
+		values := make(map[string]string)
+		for _, variant := range setting.Variants {
+			if !variant.Commented {
+				values[variant.Key] = variant.Resolved
+			}
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		cells := make([]MatrixCell, len(contexts))
+		for i, ctx := range contexts {
+			own := setting.Key
+			if ctx != "" {
+				own += "." + ctx
+			}
+
+			for _, k := range candidateKeys(setting.Key, ctx, app) {
+				v, found := values[k]
+				if !found {
+					continue
+				}
+
+				if strings.HasPrefix(v, "*EHE*") {
+					v = secretMask
+				}
+
+				cells[i] = MatrixCell{
+					Value:     v,
+					From:      k,
+					Inherited: k != own && k != own+"."+app,
+				}
+				break
+			}
+		}
+
+		m.Keys = append(m.Keys, setting.Key)
+		m.Cells[setting.Key] = cells
+	}
+
+	return m
+}
+
+func contextHeader(ctx string) string {
+	if ctx == "" {
+		return "(default)"
+	}
+
+	return ctx
+}
+
+// inheritedMark is appended to inherited values in CSV output, which has no
+// other way of styling a cell.
+const inheritedMark = " ↑"
+
+func writeMatrix(w io.Writer, m *Matrix, format string) error {
+	switch format {
+	case "md", "markdown":
+		return writeMatrixMarkdown(w, m)
+	case "csv":
+		return writeMatrixCSV(w, m)
+	case "html":
+		return writeMatrixHTML(w, m)
+	}
+
+	return fmt.Errorf("unknown matrix format %q, expected md, csv or html", format)
+}
+
+func writeMatrixMarkdown(w io.Writer, m *Matrix) error {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+
+	var builder strings.Builder
+
+	builder.WriteString("| Key |")
+	for _, ctx := range m.Contexts {
+		builder.WriteString(" " + escape.Replace(contextHeader(ctx)) + " |")
+	}
+	builder.WriteString("\n|-----|")
+	builder.WriteString(strings.Repeat("-----|", len(m.Contexts)))
+	builder.WriteString("\n")
+
+	for _, key := range m.Keys {
+		builder.WriteString("| " + escape.Replace(key) + " |")
+		for _, cell := range m.Cells[key] {
+			text := escape.Replace(cell.Value)
+			if cell.Inherited && text != "" {
+				text = "_" + text + "_"
+			}
+			builder.WriteString(" " + text + " |")
+		}
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("\n_Italic_ values are inherited from a parent context.\n")
+
+	_, err := io.WriteString(w, builder.String())
+
+	return err
+}
+
+func writeMatrixCSV(w io.Writer, m *Matrix) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"key"}
+	for _, ctx := range m.Contexts {
+		header = append(header, contextHeader(ctx))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, key := range m.Keys {
+		row := []string{key}
+		for _, cell := range m.Cells[key] {
+			text := cell.Value
+			if cell.Inherited {
+				text += inheritedMark
+			}
+			row = append(row, text)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func writeMatrixHTML(w io.Writer, m *Matrix) error {
+	var builder strings.Builder
+
+	builder.WriteString("<table class='matrix'>\n<thead><tr><th>Key</th>")
+	for _, ctx := range m.Contexts {
+		builder.WriteString("<th>" + html.EscapeString(contextHeader(ctx)) + "</th>")
+	}
+	builder.WriteString("</tr></thead>\n<tbody>\n")
+
+	for _, key := range m.Keys {
+		builder.WriteString("<tr><td>" + html.EscapeString(key) + "</td>")
+		for _, cell := range m.Cells[key] {
+			if cell.Inherited {
+				fmt.Fprintf(&builder, "<td class='inherited' title='from %s'><i>%s</i></td>", html.EscapeString(cell.From), html.EscapeString(cell.Value))
+			} else {
+				builder.WriteString("<td>" + html.EscapeString(cell.Value) + "</td>")
+			}
+		}
+		builder.WriteString("</tr>\n")
+	}
+
+	builder.WriteString("</tbody>\n</table>\n")
+
+	_, err := io.WriteString(w, builder.String())
+
+	return err
+}
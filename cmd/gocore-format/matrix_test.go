@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readMatrixSettings(t *testing.T) []*Setting {
+	settings, err := readSettings(strings.NewReader(`
+url         = http://localhost
+url.live    = https://example.com
+url.live.uk = https://example.co.uk
+# url.stage = https://stage.example.com
+port.live   = 443
+secret      = *EHE*abcdef
+`))
+	require.NoError(t, err)
+	sortSettings(settings)
+
+	return settings
+}
+
+func TestCandidateKeys(t *testing.T) {
+	assert.Equal(t, []string{"url.live.uk", "url.live", "url"}, candidateKeys("url", "live.uk", ""))
+	assert.Equal(t, []string{"url.live.app", "url.live", "url.app", "url"}, candidateKeys("url", "live", "app"))
+	assert.Equal(t, []string{"url"}, candidateKeys("url", "", ""))
+}
+
+func TestMatrixContexts(t *testing.T) {
+	assert.Equal(t, []string{"", "live", "live.uk"}, matrixContexts(readMatrixSettings(t)))
+}
+
+func TestBuildMatrix(t *testing.T) {
+	m := buildMatrix(readMatrixSettings(t), []string{"", "live", "live.uk", "stage"}, "")
+
+	assert.Equal(t, []string{"port", "secret", "url"}, m.Keys)
+
+	url := m.Cells["url"]
+	assert.Equal(t, MatrixCell{Value: "http://localhost", From: "url"}, url[0])
+	assert.Equal(t, MatrixCell{Value: "https://example.com", From: "url.live"}, url[1])
+	assert.Equal(t, MatrixCell{Value: "https://example.co.uk", From: "url.live.uk"}, url[2])
+	assert.Equal(t, MatrixCell{Value: "http://localhost", From: "url", Inherited: true}, url[3])
+
+	port := m.Cells["port"]
+	assert.Equal(t, MatrixCell{}, port[0])
+	assert.Equal(t, MatrixCell{Value: "443", From: "port.live", Inherited: true}, port[2])
+
+	assert.Equal(t, secretMask, m.Cells["secret"][0].Value)
+}
+
+func TestWriteMatrix(t *testing.T) {
+	m := buildMatrix(readMatrixSettings(t), []string{"", "live.uk"}, "")
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeMatrix(buf, m, "md"))
+	assert.Equal(t, `| Key | (default) | live.uk |
+|-----|-----|-----|
+| port |  | _443_ |
+| secret | ******************** | _********************_ |
+| url | http://localhost | https://example.co.uk |
+
+_Italic_ values are inherited from a parent context.
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeMatrix(buf, m, "csv"))
+	assert.Equal(t, `key,(default),live.uk
+port,,443 ↑
+secret,********************,******************** ↑
+url,http://localhost,https://example.co.uk
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeMatrix(buf, m, "html"))
+	assert.Contains(t, buf.String(), "<td class='inherited' title='from port.live'><i>443</i></td>")
+
+	assert.Error(t, writeMatrix(buf, m, "pdf"))
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ordishs/gocore/parser"
+)
+
+// Setting is a root key together with everything needed to generate its
+// accessor.
+type Setting struct {
+	Key      string
+	Group    string
+	Type     string // From a "# @type" annotation, empty if not annotated
+	Comments []string
+	Values   []string
+}
+
+// accessor describes the generated function for one setting type.
+type accessor struct {
+	defaultType string
+	results     string
+	method      string
+	imports     []string
+}
+
+var accessors = map[string]accessor{
+	"string":   {"string", "(string, bool)", "Get", nil},
+	"int":      {"int", "(int, bool)", "GetInt", nil},
+	"int64":    {"int64", "(int64, bool)", "GetInt64", nil},
+	"float64":  {"float64", "(float64, bool)", "GetFloat64", nil},
+	"bool":     {"bool", "bool", "GetBool", nil},
+	"duration": {"time.Duration", "(time.Duration, error, bool)", "GetDuration", []string{"time"}},
+	"url":      {"string", "(*url.URL, error, bool)", "GetURL", []string{"net/url"}},
+	"bytesize": {"uint64", "(uint64, bool)", "GetByteSize", nil},
+	"time":     {"time.Time", "(time.Time, bool)", "GetTime", []string{"time"}},
+}
+
+var typeAliases = map[string]string{
+	"float": "float64",
+	"size":  "bytesize",
+}
+
+var initialisms = map[string]string{
+	"api":  "API",
+	"dns":  "DNS",
+	"grpc": "GRPC",
+	"http": "HTTP",
+	"id":   "ID",
+	"ip":   "IP",
+	"json": "JSON",
+	"rpc":  "RPC",
+	"sql":  "SQL",
+	"tls":  "TLS",
+	"url":  "URL",
+}
+
+func main() {
+	var (
+		help        bool
+		packageName string
+		output      string
+		filename    = "settings.conf"
+	)
+
+	flag.BoolVar(&help, "h", false, "Help")
+	flag.StringVar(&packageName, "package", "settings", "Name of the generated package")
+	flag.StringVar(&output, "o", "", "Output file (default stdout)")
+	flag.Parse()
+
+	if help {
+		fmt.Println("Usage: gocore-gen [-package name] [-o file] [settings.conf]")
+		flag.PrintDefaults()
+		return
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		filename = args[0]
+	}
+
+	in, err := os.Open(filename)
+	if err != nil {
+		fmt.Println("Error opening file:", err)
+		os.Exit(1)
+	}
+	defer in.Close()
+
+	settings, err := readSettings(in)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		os.Exit(1)
+	}
+
+	src, err := generate(packageName, filename, settings)
+	if err != nil {
+		fmt.Println("Error generating code:", err)
+		os.Exit(1)
+	}
+
+	if output == "" {
+		_, _ = os.Stdout.Write(src)
+		return
+	}
+
+	if err := os.WriteFile(output, src, 0644); err != nil {
+		fmt.Println("Error writing file:", err)
+		os.Exit(1)
+	}
+}
+
+// readSettings groups the settings by root key, in the order they first
+// appear.  Comment lines directly above the first occurrence of a root key
+// become its doc comment, in the same way gocore-format keeps them together.
+func readSettings(r io.Reader) ([]*Setting, error) {
+	var (
+		settings []*Setting
+		byKey    = make(map[string]*Setting)
+	)
+
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parser.Parse("", src)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, doc := range parser.Document(entries) {
+		entry := entries[i]
+		if entry.Kind != parser.Setting {
+			continue
+		}
+
+		typ := doc.Type
+		if alias, found := typeAliases[typ]; found {
+			typ = alias
+		}
+		if _, found := accessors[typ]; typ != "" && !found {
+			return nil, fmt.Errorf("line %d: unknown type %q for %s", entry.Line, typ, entry.Key)
+		}
+
+		rootKey := strings.Split(entry.Key, ".")[0]
+
+		setting, found := byKey[rootKey]
+		if !found {
+			setting = &Setting{
+				Key:      rootKey,
+				Group:    doc.Group,
+				Comments: doc.Comments,
+			}
+			byKey[rootKey] = setting
+			settings = append(settings, setting)
+		}
+
+		if typ != "" {
+			setting.Type = typ
+		}
+
+		setting.Values = append(setting.Values, entry.Value)
+	}
+
+	return settings, nil
+}
+
+// inferType picks the narrowest type that every value of the setting parses
+// as.  Values that use ${} interpolation are only known at runtime, so they
+// are always strings.
+func inferType(values []string) string {
+	var nonEmpty []string
+	for _, v := range values {
+		if strings.Contains(v, "${") {
+			return "string"
+		}
+		if v != "" {
+			nonEmpty = append(nonEmpty, v)
+		}
+	}
+
+	if len(nonEmpty) == 0 {
+		return "string"
+	}
+
+	all := func(parse func(string) error) bool {
+		for _, v := range nonEmpty {
+			if parse(v) != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case all(parseBool):
+		return "bool"
+	case all(func(s string) error { _, err := strconv.Atoi(s); return err }):
+		return "int"
+	case all(func(s string) error { _, err := strconv.ParseFloat(s, 64); return err }):
+		return "float64"
+	case all(func(s string) error { _, err := time.ParseDuration(s); return err }):
+		return "duration"
+	}
+
+	return "string"
+}
+
+// parseBool only accepts the words true and false, as values such as 1 or t
+// are more likely to be numbers or strings.
+func parseBool(s string) error {
+	switch strings.ToLower(s) {
+	case "true", "false":
+		return nil
+	}
+
+	return strconv.ErrSyntax
+}
+
+// identifier converts a key such as database_max_conns or kafka-url into an
+// exported Go name such as DatabaseMaxConns or KafkaURL.
+func identifier(key string) string {
+	parts := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var builder strings.Builder
+	for _, part := range parts {
+		if upper, found := initialisms[strings.ToLower(part)]; found {
+			builder.WriteString(upper)
+			continue
+		}
+
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		builder.WriteString(string(runes))
+	}
+
+	name := builder.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "Setting" + name
+	}
+
+	return name
+}
+
+func generate(packageName, filename string, settings []*Setting) ([]byte, error) {
+	imports := make(map[string]struct{})
+	names := make(map[string]string)
+
+	var body bytes.Buffer
+
+	for _, setting := range settings {
+		typ := setting.Type
+		if typ == "" {
+			typ = inferType(setting.Values)
+		}
+		acc := accessors[typ]
+
+		name := identifier(setting.Key)
+		if other, found := names[name]; found {
+			return nil, fmt.Errorf("keys %q and %q both generate %s", other, setting.Key, name)
+		}
+		names[name] = setting.Key
+
+		for _, imp := range acc.imports {
+			imports[imp] = struct{}{}
+		}
+
+		fmt.Fprintf(&body, "\n// %s returns the %s setting.\n", name, setting.Key)
+		if len(setting.Comments) > 0 || setting.Group != "" {
+			body.WriteString("//\n")
+		}
+		for _, comment := range setting.Comments {
+			fmt.Fprintf(&body, "// %s\n", comment)
+		}
+		if setting.Group != "" {
+			if len(setting.Comments) > 0 {
+				body.WriteString("//\n")
+			}
+			fmt.Fprintf(&body, "// Group: %s\n", setting.Group)
+		}
+		fmt.Fprintf(&body, "func %s(defaultValue ...%s) %s {\n", name, acc.defaultType, acc.results)
+		fmt.Fprintf(&body, "\treturn gocore.Config().%s(%q, defaultValue...)\n", acc.method, setting.Key)
+		body.WriteString("}\n")
+	}
+
+	paths := make([]string, 0, len(imports))
+	for imp := range imports {
+		paths = append(paths, imp)
+	}
+	sort.Strings(paths)
+
+	var src bytes.Buffer
+
+	fmt.Fprintf(&src, "// Code generated by gocore-gen from %s. DO NOT EDIT.\n\n", filename)
+	fmt.Fprintf(&src, "package %s\n\n", packageName)
+	src.WriteString("import (\n")
+	for _, imp := range paths {
+		fmt.Fprintf(&src, "\t%q\n", imp)
+	}
+	if len(paths) > 0 {
+		src.WriteString("\n")
+	}
+	src.WriteString("\t\"github.com/ordishs/gocore\"\n")
+	src.WriteString(")\n")
+	src.Write(body.Bytes())
+
+	return format.Source(src.Bytes())
+}
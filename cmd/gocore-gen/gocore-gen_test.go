@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferType(t *testing.T) {
+	assert.Equal(t, "bool", inferType([]string{"true", "FALSE"}))
+	assert.Equal(t, "int", inferType([]string{"5432", "", "6543"}))
+	assert.Equal(t, "float64", inferType([]string{"1", "0.5"}))
+	assert.Equal(t, "duration", inferType([]string{"5s", "1m"}))
+	assert.Equal(t, "string", inferType([]string{"5s", "soon"}))
+	assert.Equal(t, "string", inferType([]string{"${port}"}))
+	assert.Equal(t, "string", inferType([]string{""}))
+}
+
+func TestIdentifier(t *testing.T) {
+	assert.Equal(t, "DatabaseMaxConns", identifier("database_max_conns"))
+	assert.Equal(t, "KafkaURL", identifier("kafka-url"))
+	assert.Equal(t, "MaxIdleConns", identifier("maxIdleConns"))
+	assert.Equal(t, "Peer0", identifier("peer[0]"))
+	assert.Equal(t, "Setting1st", identifier("1st"))
+}
+
+func TestGenerate(t *testing.T) {
+	reader := strings.NewReader(`
+# The database host
+# used by every service
+database_host      = localhost
+database_host.live = db.example.com
+
+# @group: timeouts
+# @type duration
+read_timeout = 5s
+# write_timeout = 10
+# @endgroup
+
+debug = false # verbose logging
+
+# @type bytesize
+cache_size = 512MB
+
+base_url = http://localhost:8080
+`)
+
+	settings, err := readSettings(reader)
+	require.NoError(t, err)
+	require.Len(t, settings, 5)
+
+	src, err := generate("settings", "settings.conf", settings)
+	require.NoError(t, err)
+
+	assert.Equal(t, `// Code generated by gocore-gen from settings.conf. DO NOT EDIT.
+
+package settings
+
+import (
+	"time"
+
+	"github.com/ordishs/gocore"
+)
+
+// DatabaseHost returns the database_host setting.
+//
+// The database host
+// used by every service
+func DatabaseHost(defaultValue ...string) (string, bool) {
+	return gocore.Config().Get("database_host", defaultValue...)
+}
+
+// ReadTimeout returns the read_timeout setting.
+//
+// Group: timeouts
+func ReadTimeout(defaultValue ...time.Duration) (time.Duration, error, bool) {
+	return gocore.Config().GetDuration("read_timeout", defaultValue...)
+}
+
+// Debug returns the debug setting.
+func Debug(defaultValue ...bool) bool {
+	return gocore.Config().GetBool("debug", defaultValue...)
+}
+
+// CacheSize returns the cache_size setting.
+func CacheSize(defaultValue ...uint64) (uint64, bool) {
+	return gocore.Config().GetByteSize("cache_size", defaultValue...)
+}
+
+// BaseURL returns the base_url setting.
+func BaseURL(defaultValue ...string) (string, bool) {
+	return gocore.Config().Get("base_url", defaultValue...)
+}
+`, string(src))
+}
+
+func TestGenerateErrors(t *testing.T) {
+	_, err := readSettings(strings.NewReader("# @type money\namount = 5\n"))
+	assert.ErrorContains(t, err, `line 2: unknown type "money" for amount`)
+
+	settings, err := readSettings(strings.NewReader("max_conns = 1\nmax-conns = 2\n"))
+	require.NoError(t, err)
+
+	_, err = generate("settings", "settings.conf", settings)
+	assert.ErrorContains(t, err, "both generate MaxConns")
+}
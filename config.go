@@ -8,6 +8,7 @@ import (
 	"html"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
@@ -21,13 +22,14 @@ import (
 	"text/tabwriter"
 	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/ordishs/gocore/parser"
 	"github.com/ordishs/gocore/utils"
 )
 
 // Configuration comment
 type Configuration struct {
 	confs      map[string]string
+	origins    map[string]string
 	context    string
 	app        string
 	requests   map[string]*requestRecord
@@ -35,6 +37,38 @@ type Configuration struct {
 	mu         sync.RWMutex
 	listeners  []SettingsListener
 	listenerMu sync.RWMutex
+	layers     *layerList
+	aliases    *aliasSet
+	flags      *flagSet
+
+	// parent and prefix are set for the scoped views returned by Sub
+	parent *Configuration
+	prefix string
+
+	cmdlineBelowEnv bool
+	envMapping      EnvMapping
+	dotenv          *dotenv
+
+	// files lists the settings files that Config() loaded, in order
+	files []string
+
+	// overridden holds the settings file values of keys changed at runtime
+	// with Set or Unset, so that they can be reverted
+	overridden map[string]originalValue
+	validators *validatorList
+
+	// changeMu is held for writing while settings change and for reading
+	// while a snapshot is taken, so that a snapshot never sees half a change
+	changeMu   sync.RWMutex
+	generation atomic.Uint64
+
+	// snapshot caches the last snapshot, which is reused until the
+	// generation changes
+	snapshot atomic.Pointer[Snapshot]
+
+	// recordTo is the configuration that a snapshot's copy records its
+	// requests on
+	recordTo *Configuration
 }
 
 var (
@@ -52,6 +86,8 @@ var (
 
 var reEHE = regexp.MustCompile(`(\*EHE\*[a-zA-Z0-9]+)`)
 
+var reVariable = regexp.MustCompile(`\$\{(.*?)\}`)
+
 const eheMask = "********************"
 
 func maskSecrets(value string) string {
@@ -64,6 +100,7 @@ func maskSecrets(value string) string {
 
 type requestRecord struct {
 	Key            string
+	Type           string
 	DefaultValue   string
 	HasDefault     bool
 	Value          string
@@ -71,6 +108,7 @@ type requestRecord struct {
 	FirstRequested time.Time
 	LastRequested  time.Time
 	Count          int64
+	Callers        []string
 }
 
 func init() {
@@ -137,7 +175,7 @@ func GetAddress() string {
 	return a
 }
 
-func processFile(m map[string]string, filename string) (string, error) {
+func processFile(m map[string]string, origins map[string]string, filename string) (string, error) {
 	// Get the directory of the application binary
 	exePath, err := os.Executable()
 	if err != nil {
@@ -189,65 +227,151 @@ func processFile(m map[string]string, filename string) (string, error) {
 		return f, err
 	}
 
-	str := string(bytesRead)
-	lines := strings.Split(str, "\n")
+	if err := parseSettings(m, origins, f, string(bytesRead)); err != nil {
+		var errs parser.ErrorList
+		if errors.As(err, &errs) {
+			for _, e := range errs {
+				log.Printf("ERROR: %v", e)
+			}
+		}
+	}
 
-	for lineNum, line := range lines {
-		if len(line) > 0 {
-			line = strings.Split(line, "#")[0]
-			pos := strings.Index(line, "=")
-			if pos != -1 {
-				key := strings.TrimSpace(line[:pos])
-				value := line[pos+1:]
-				value = strings.TrimSpace(value)
+	return f, nil
+}
 
-				// As an edge case, remove the first and last characters
-				// if they are both double quotes
-				if len(value) > 2 && value[0] == '"' && value[len(value)-1] == '"' {
-					value = value[1 : len(value)-1]
-				}
+// parseSettings adds the settings in str to m.  If origins is not nil, the
+// file:line that declared each key is stored in it.  Settings on lines with
+// syntax errors are skipped and reported in the returned error.
+func parseSettings(m map[string]string, origins map[string]string, f string, str string) error {
+	entries, err := parser.Parse(f, []byte(str))
 
-				oldVal, found := m[key]
-				if found {
-					log.Printf("INFO: %s:%d is replacing %q: %q -> %q", f, lineNum+1, key, oldVal, value)
-				}
-				m[key] = value
-			}
+	for _, e := range entries {
+		if e.Kind != parser.Setting {
+			continue
+		}
+
+		oldVal, found := m[e.Key]
+		if found {
+			log.Printf("INFO: %s:%d is replacing %q: %q -> %q", f, e.Line, e.Key, oldVal, e.Value)
+		}
+		m[e.Key] = e.Value
+
+		if origins != nil {
+			origins[e.Key] = fmt.Sprintf("%s:%d", f, e.Line)
 		}
 	}
 
-	return f, nil
+	return err
 }
 
-// Config returns a Configuration object
-func Config(alternativeContext ...string) *Configuration {
-	once.Do(func() {
-		// Before processing settings, use dotenv to load any environment variables .env file
-
-		// The .env file can be overridden by setting the environment variable
-		// SETTINGS_ENV_FILE to the full path of the file.
-		// For example:
-		//   SETTINGS_ENV_FILE=/home/user/.env
-		//	 SETTINGS_ENV_FILE=/home/user/.env.dev
-		//   SETTINGS_ENV_FILE=/home/user/.env.prod
-		//
-		// If the environment variable is not set, then the default file name is .env
-		envFile := os.Getenv("SETTINGS_ENV_FILE")
-		if envFile == "" {
-			envFile = ".env"
+// NewConfiguration returns a standalone Configuration for the given context
+// and application, with no settings.  Unlike Config, it does not read any
+// files or the environment, and is not advertised.  Use AddSettings to load
+// settings into it.
+func NewConfiguration(context, app string) *Configuration {
+	return &Configuration{
+		confs:      make(map[string]string),
+		origins:    make(map[string]string),
+		context:    context,
+		app:        app,
+		requests:   make(map[string]*requestRecord),
+		envMapping: EnvMapping{DisableBareKey: true},
+	}
+}
+
+// AddSettings parses content in settings.conf format and adds its settings,
+// replacing any with the same key.  filename is used to record where each
+// setting came from and in error messages.  Syntax errors are returned as a
+// parser.ErrorList, and the settings on the lines without errors are still
+// added.  Like Set, each change is checked by the validators, which can
+// reject it, and listeners are notified of the settings that changed.
+func (c *Configuration) AddSettings(filename string, content []byte) error {
+	c = c.root()
+
+	values := make(map[string]string)
+	origins := make(map[string]string)
+	err := parseSettings(values, origins, filename, string(content))
+
+	c.changeMu.Lock()
+
+	changed := make(map[string]string)
+	for k, v := range values {
+		c.mu.RLock()
+		old, found := c.confs[k]
+		c.mu.RUnlock()
+
+		if found && old == v {
+			continue
 		}
 
-		if _, err := os.Stat(envFile); err == nil {
-			err := godotenv.Load(envFile)
-			if err != nil {
-				log.Print("WARN: failed to loading .env file")
-			}
+		if verr := c.validate(k, v); verr != nil {
+			log.Printf("WARN: Ignoring %s change - [%v]", filename, verr)
+			delete(values, k)
+			continue
+		}
+
+		if found {
+			log.Printf("INFO: %s is replacing %q: %q -> %q", origins[k], k, old, v)
 		}
+		changed[k] = v
+	}
+
+	c.mu.Lock()
+	if c.confs == nil {
+		c.confs = make(map[string]string)
+	}
+	if c.origins == nil {
+		c.origins = make(map[string]string)
+	}
+	for k := range values {
+		c.confs[k] = values[k]
+		c.origins[k] = origins[k]
+	}
+	c.generation.Add(1)
+	c.mu.Unlock()
+
+	c.changeMu.Unlock()
+
+	for k, v := range changed {
+		c.notifyListeners(k, v)
+	}
+
+	return err
+}
+
+// LoadSettingsFile is AddSettings for a file on disk.
+func (c *Configuration) LoadSettingsFile(filename string) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
 
+	return c.AddSettings(filename, content)
+}
+
+// SettingsFiles returns the settings files that Config() loads, in order.
+// Tools that read settings files outside an application, such as gocore-diff
+// and gocore-config, load the same files by default.
+func SettingsFiles() []string {
+	return []string{"settings.conf", "settings_test.conf", "settings_local.conf"}
+}
+
+// Config returns a Configuration object
+func Config(alternativeContext ...string) *Configuration {
+	once.Do(func() {
 		c = new(Configuration)
 
+		// Before processing settings, read .env, .env.<context>, .env.<app>
+		// and .env.local, or the single file named by SETTINGS_ENV_FILE.
+		// Their variables are looked up just after the environment, and can
+		// also set the SETTINGS_* variables below.
+		c.dotenv = loadDotenv("")
+		for _, file := range c.dotenv.files {
+			logInfof("INFO: Loaded env file '%s'", file)
+		}
+
 		// Set the context by checking the environment variable SETTINGS_CONTEXT
-		env := os.Getenv("SETTINGS_CONTEXT")
+		env := c.dotenv.getenv("SETTINGS_CONTEXT")
 		if env != "" {
 			c.context = env
 		} else {
@@ -255,15 +379,16 @@ func Config(alternativeContext ...string) *Configuration {
 		}
 
 		// Set the application by checking the environment variable SETTINGS_APPLICATION
-		app := os.Getenv("SETTINGS_APPLICATION")
+		app := c.dotenv.getenv("SETTINGS_APPLICATION")
 		if app != "" {
 			c.app = app
 		}
 
 		c.confs = make(map[string]string, 0)
+		c.origins = make(map[string]string)
 		c.requests = make(map[string]*requestRecord)
 
-		filename, err := processFile(c.confs, "settings.conf")
+		filename, err := processFile(c.confs, c.origins, "settings.conf")
 		if err != nil {
 			if os.IsNotExist(err) {
 				filename = "NOT FOUND"
@@ -272,6 +397,8 @@ func Config(alternativeContext ...string) *Configuration {
 				log.Printf("FATAL: Failed to read config  file '%s' - [%v]", filename, err)
 				os.Exit(1)
 			}
+		} else {
+			c.files = append(c.files, filename)
 		}
 
 		// // Load infrastructure settings
@@ -287,14 +414,15 @@ func Config(alternativeContext ...string) *Configuration {
 		// }
 
 		// Load settings_test.conf, if it exists. If not, it's not a problem.
-		testFilename, err := processFile(c.confs, "settings_test.conf")
+		testFilename, err := processFile(c.confs, c.origins, "settings_test.conf")
 		if err == nil {
 			// There was a settings_test.conf loaded.  Log the filename...
 			logInfof("INFO: Loaded test config file '%s'", testFilename)
+			c.files = append(c.files, testFilename)
 		}
 
 		// Load local overrides last
-		localFilename, err := processFile(c.confs, "settings_local.conf")
+		localFilename, err := processFile(c.confs, c.origins, "settings_local.conf")
 		if err != nil {
 			if os.IsNotExist(err) {
 				localFilename = "NOT FOUND"
@@ -303,6 +431,68 @@ func Config(alternativeContext ...string) *Configuration {
 				log.Printf("FATAL: Failed to read local config '%s' - [%v]", localFilename, err)
 				os.Exit(1)
 			}
+		} else {
+			c.files = append(c.files, localFilename)
+		}
+
+		// Load mounted secrets (one file per key), if configured
+		if secretsDir := c.dotenv.getenv("SETTINGS_SECRETS_DIR"); secretsDir != "" {
+			if err := c.loadSecretsDir(secretsDir); err != nil {
+				log.Printf("WARN: Failed to read secrets directory '%s' - [%v]", secretsDir, err)
+			} else {
+				logInfof("INFO: Loaded secrets directory '%s'", secretsDir)
+
+				pollInterval, _ := c.Get("secretsPollInterval", "10s")
+				interval, err := time.ParseDuration(pollInterval)
+				if err != nil {
+					interval = 10 * time.Second
+				}
+
+				c.watchSecretsDir(secretsDir, interval)
+			}
+		}
+
+		// Map keys such as url.live to prefixed environment variables such as
+		// APP_URL__LIVE when SETTINGS_ENV_PREFIX is set.  Bare keys are still
+		// honoured unless SETTINGS_ENV_BARE_KEYS=false.
+		if prefix := c.dotenv.getenv("SETTINGS_ENV_PREFIX"); prefix != "" {
+			c.envMapping = EnvMapping{
+				Prefix:       prefix,
+				DotSeparator: "__",
+				UpperCase:    true,
+			}
+		}
+		if c.dotenv.getenv("SETTINGS_ENV_BARE_KEYS") == "false" {
+			c.envMapping.DisableBareKey = true
+		}
+
+		// Apply any --set and --settings-file overrides from the command line.
+		// SETTINGS_CMDLINE_PRECEDENCE=below_env lets the environment win.
+		if c.dotenv.getenv("SETTINGS_CMDLINE_PRECEDENCE") == "below_env" {
+			c.cmdlineBelowEnv = true
+		}
+
+		cmdline, _, err := ParseSettingsArgs(os.Args[1:])
+		if err != nil {
+			log.Printf("WARN: Ignoring command line settings - [%v]", err)
+		} else if len(cmdline) > 0 {
+			c.SetCommandLineSettings(cmdline)
+		}
+
+		// Pull settings from a remote config service, if configured
+		if remoteURL, _ := c.Get("remoteConfigURL"); remoteURL != "" {
+			c.startRemoteConfig(remoteURL)
+		}
+
+		// Optionally log which settings are unused or undeclared once the
+		// application has had a chance to request them
+		if coverageDelay, _ := c.Get("settingsCoverageLogDelay"); coverageDelay != "" {
+			delay, err := time.ParseDuration(coverageDelay)
+			if err != nil {
+				log.Printf("WARN: Invalid settingsCoverageLogDelay %q - [%v]", coverageDelay, err)
+			} else {
+				c.logCoverageAfter(delay)
+			}
 		}
 
 		advertisingURL, _ := c.Get("advertisingURL")
@@ -325,6 +515,8 @@ func Config(alternativeContext ...string) *Configuration {
 
 			executable := os.Args[0]
 
+			cfg := c
+
 			go func() {
 				time.Sleep(1 * time.Second) // Sleep for 1 second to let packageName to be set
 
@@ -345,6 +537,8 @@ func Config(alternativeContext ...string) *Configuration {
 					Host              string                 `json:"host"`
 					Address           string                 `json:"address"`
 					StartTime         string                 `json:"startTime"`
+					ConfigGeneration  uint64                 `json:"configGeneration"`
+					ConfigHash        string                 `json:"configHash"`
 					AppPayload        map[string]interface{} `json:"appPayload"`
 				}
 
@@ -383,6 +577,10 @@ func Config(alternativeContext ...string) *Configuration {
 					}
 					appMu.RUnlock()
 
+					// The generation and hash let a dashboard spot instances
+					// whose configuration has drifted
+					snapshot := cfg.Snapshot()
+
 					j, err := json.Marshal(&payload{
 						Executable:        executable,
 						ServiceName:       p,
@@ -398,6 +596,8 @@ func Config(alternativeContext ...string) *Configuration {
 						Host:             host,
 						Address:          addressStr,
 						StartTime:        startTime,
+						ConfigGeneration: snapshot.Generation(),
+						ConfigHash:       snapshot.Hash(),
 						AppPayload:       appPayloads,
 					})
 
@@ -439,19 +639,7 @@ func Config(alternativeContext ...string) *Configuration {
 			return ac
 		}
 
-		ac = new(Configuration)
-		ac.mu.Lock()
-		defer ac.mu.Unlock()
-
-		ac.context = alternativeContext[0]
-		ac.app = c.app
-		// Copy the confs
-		ac.confs = make(map[string]string)
-		for k, v := range c.confs {
-			ac.confs[k] = v
-		}
-
-		ac.requests = make(map[string]*requestRecord)
+		ac = c.withContext(alternativeContext[0])
 
 		alternativeConfigs[alternativeContext[0]] = ac
 
@@ -461,6 +649,35 @@ func Config(alternativeContext ...string) *Configuration {
 	return c
 }
 
+// withContext returns a copy of c that resolves settings for another context.
+// The confs are copied, while the origins, layers, aliases and validators are
+// shared, so that layers and validators added to c later, such as the
+// secrets, apply to the copy too.
+func (c *Configuration) withContext(context string) *Configuration {
+	ac := new(Configuration)
+
+	ac.context = context
+	ac.app = c.app
+
+	c.mu.RLock()
+	ac.confs = make(map[string]string, len(c.confs))
+	for k, v := range c.confs {
+		ac.confs[k] = v
+	}
+	ac.origins = c.origins
+	ac.files = append([]string(nil), c.files...)
+	c.mu.RUnlock()
+
+	ac.requests = make(map[string]*requestRecord)
+	ac.layers = c.layerList()
+	ac.validators = c.validatorList()
+	ac.envMapping = c.getEnvMapping()
+	ac.dotenv = c.getDotenv()
+	ac.aliases = c.aliases
+
+	return ac
+}
+
 func logInfof(msg string, args ...interface{}) {
 	log.Printf(msg, args...)
 }
@@ -505,40 +722,94 @@ type SettingsListener interface {
 	UpdateSetting(key string, value string)
 }
 
-// Set an item in the config
+// Set an item in the config.  If a validator rejects the value, the setting
+// is left unchanged, the rejection is logged and the current value is
+// returned.  Use TrySet to get the error.
 func (c *Configuration) Set(key string, value string) string {
+	oldValue, err := c.TrySet(key, value)
+	if err != nil {
+		log.Printf("WARN: Setting not changed - [%v]", err)
+	}
+
+	return oldValue
+}
+
+// TrySet is Set, but returns the error from any validator that rejects the
+// value.
+func (c *Configuration) TrySet(key string, value string) (string, error) {
+	c, key = c.root(), c.qualify(key)
+
+	c.changeMu.Lock()
+
+	if err := c.validate(key, value); err != nil {
+		c.changeMu.Unlock()
+		return c.confValue(key), err
+	}
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.rememberOriginal(key)
+	c.generation.Add(1)
 
 	oldValue := c.confs[key]
 	c.confs[key] = value
+	c.mu.Unlock()
 
-	// Notify all listeners of the change
-	c.listenerMu.RLock()
-	for _, listener := range c.listeners {
-		listener.UpdateSetting(key, value)
+	c.changeMu.Unlock()
+
+	// Notify all listeners of the change once it is complete, so that they
+	// can read settings or take a snapshot
+	c.notifyListeners(key, value)
+
+	return oldValue, nil
+}
+
+// Unset removes an item from the config.  If a validator rejects the
+// removal, the setting is left in place and the rejection is logged.
+func (c *Configuration) Unset(key string) string {
+	oldValue, err := c.TryUnset(key)
+	if err != nil {
+		log.Printf("WARN: Setting not removed - [%v]", err)
+		return ""
 	}
-	c.listenerMu.RUnlock()
 
 	return oldValue
 }
 
-// Unset removes an item from the config
-func (c *Configuration) Unset(key string) string {
+// TryUnset is Unset, but returns the error from any validator that rejects
+// the removal.
+func (c *Configuration) TryUnset(key string) (string, error) {
+	c, key = c.root(), c.qualify(key)
+
+	c.changeMu.Lock()
+
+	if err := c.validate(key, ""); err != nil {
+		c.changeMu.Unlock()
+		return "", err
+	}
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.rememberOriginal(key)
+	c.generation.Add(1)
 
 	oldValue := c.confs[key]
 	delete(c.confs, key)
+	c.mu.Unlock()
+
+	c.changeMu.Unlock()
 
 	// Notify all listeners that the setting was removed
+	c.notifyListeners(key, "")
+
+	return oldValue, nil
+}
+
+func (c *Configuration) notifyListeners(key string, value string) {
 	c.listenerMu.RLock()
+	defer c.listenerMu.RUnlock()
+
 	for _, listener := range c.listeners {
-		listener.UpdateSetting(key, "")
+		listener.UpdateSetting(key, value)
 	}
-	c.listenerMu.RUnlock()
-
-	return oldValue
 }
 
 func (c *Configuration) decrypt(val string) string {
@@ -571,13 +842,19 @@ func (c *Configuration) replaceVariables(value string) string {
 	return value
 }
 
-func (c *Configuration) record(key string, hasDefault bool, defaultStr, value, source string) {
-	masked := maskSecrets(value)
-	maskedDefault := maskSecrets(defaultStr)
+func (c *Configuration) record(key string, typ string, hasDefault bool, defaultStr, value, source string) {
+	c, key = c.root(), c.qualify(key)
+
+	if c.recordTo != nil {
+		c.recordTo.record(key, typ, hasDefault, defaultStr, value, source)
+		return
+	}
+
+	masked := maskValue(value, source)
 
 	now := time.Now().UTC()
 
-	mapKey := fmt.Sprintf("%s\x00%t\x00%s", key, hasDefault, defaultStr)
+	mapKey := key + "\x00" + typ + "\x00" + strconv.FormatBool(hasDefault) + "\x00" + defaultStr
 
 	c.rmu.Lock()
 	defer c.rmu.Unlock()
@@ -587,12 +864,19 @@ func (c *Configuration) record(key string, hasDefault bool, defaultStr, value, s
 		rec.Source = source
 		rec.LastRequested = now
 		rec.Count++
+
+		// Finding the caller is relatively expensive, so after the first
+		// request only a sample of them is looked at
+		if rec.Count&(rec.Count-1) == 0 && len(rec.Callers) < maxCallers {
+			rec.addCaller(requestCaller())
+		}
 		return
 	}
 
-	c.requests[mapKey] = &requestRecord{
+	rec := &requestRecord{
 		Key:            key,
-		DefaultValue:   maskedDefault,
+		Type:           typ,
+		DefaultValue:   maskSecrets(defaultStr),
 		HasDefault:     hasDefault,
 		Value:          masked,
 		Source:         source,
@@ -600,6 +884,9 @@ func (c *Configuration) record(key string, hasDefault bool, defaultStr, value, s
 		LastRequested:  now,
 		Count:          1,
 	}
+	rec.addCaller(requestCaller())
+
+	c.requests[mapKey] = rec
 }
 
 func (c *Configuration) Get(key string, defaultValue ...string) (string, bool) {
@@ -611,29 +898,20 @@ func (c *Configuration) Get(key string, defaultValue ...string) (string, bool) {
 		defStr = defaultValue[0]
 	}
 
-	c.record(key, hasDefault, defStr, s, source)
+	c.record(key, "string", hasDefault, defStr, s, source)
 
 	return strings.TrimPrefix(s, "*EHE*"), ok
 }
 
 // Get (key, defaultValue)
 func (c *Configuration) getInternal(key string, defaultValue ...string) (string, bool, string) {
-	env, ok := os.LookupEnv(key)
-	if ok {
-		// Replace variables in the value
-		env = c.replaceVariables(env)
+	c, key = c.root(), c.qualify(key)
 
-		return c.decrypt(env), true, "ENV"
-	}
-
-	ret, ok, keyUsed := c.findValue(key)
-	if ok {
-		// Replace variables in the value
-		ret = c.replaceVariables(ret)
-
-		return c.decrypt(ret), ok, keyUsed
+	if val, ok, source := c.resolveAliased(key); ok {
+		return val, ok, source
 	}
 
+	ret := ""
 	if len(defaultValue) > 0 {
 		ret = defaultValue[0]
 	}
@@ -644,36 +922,63 @@ func (c *Configuration) getInternal(key string, defaultValue ...string) (string,
 	return c.decrypt(ret), false, "DEFAULT"
 }
 
-func (c *Configuration) findValue(key string) (ret string, ok bool, k string) {
+// resolve looks key up in the layers, the environment and the settings files,
+// in that order of precedence.
+func (c *Configuration) resolve(key string) (string, bool, string) {
+	val, ok, source := c.resolveRaw(key)
+	if !ok {
+		return "", false, ""
+	}
+
+	// Replace variables in the value
+	return c.decrypt(c.replaceVariables(val)), true, source
+}
+
+// resolveRaw is resolve without variable replacement or decryption.
+func (c *Configuration) resolveRaw(key string) (string, bool, string) {
+	val, source, _, ok := c.resolveKeys(key, c.candidateKeys(key), []string{key})
+
+	return val, ok, source
+}
+
+// resolveKeys looks for the first of candidates, most specific first, in each
+// of the layers, the environment and the settings files, in that order of
+// precedence.  bare are the names that are also tried in the environment as
+// they are.  It returns the raw value, its provenance label and the key that
+// supplied it.
+func (c *Configuration) resolveKeys(key string, candidates, bare []string) (string, string, string, bool) {
+	if val, source, keyUsed, ok := c.lookupLayers(key, candidates, priorityEnv, math.MaxInt); ok {
+		return val, source, keyUsed, true
+	}
+
+	if env, source, keyUsed, ok := c.lookupEnvKeys(key, candidates, bare); ok {
+		return env, source, keyUsed, true
+	}
+
+	if val, source, keyUsed, ok := c.lookupLayers(key, candidates, math.MinInt, priorityEnv); ok {
+		return val, source, keyUsed, true
+	}
+
+	if ret, ok, keyUsed := c.findValue(candidates); ok {
+		return ret, keyUsed, keyUsed, true
+	}
+
+	return "", "", "", false
+}
+
+func (c *Configuration) findValue(candidates []string) (ret string, ok bool, k string) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	// Start with a copy of the context, i.e. "live.context"
-	k = key
-	if c.context != "" {
-		k += "." + c.context
-	}
-	for !ok {
-		if c.app != "" {
-			ret, ok = c.confs[k+"."+c.app]
-			if ok {
-				k = k + "." + c.app
-				return
-			}
-		}
+	// Try the most specific key first, i.e. "key.live.context.app"
+	for _, k = range candidates {
 		ret, ok = c.confs[k]
 		if ok {
 			return
-		} else {
-			pos := strings.LastIndex(k, ".")
-			if pos == -1 {
-				break
-			}
-			k = k[:pos]
 		}
 	}
 
-	return
+	return "", false, ""
 }
 
 func (c *Configuration) GetMulti(key string, sep string, defaultValue ...[]string) ([]string, bool) {
@@ -688,14 +993,14 @@ func (c *Configuration) GetMulti(key string, sep string, defaultValue ...[]strin
 
 	if str == "" || !ok {
 		if hasDefault {
-			c.record(key, hasDefault, defStr, defStr, "DEFAULT")
+			c.record(key, "[]string", hasDefault, defStr, defStr, "DEFAULT")
 			return defaultValue[0], false
 		}
-		c.record(key, hasDefault, defStr, "", "DEFAULT")
+		c.record(key, "[]string", hasDefault, defStr, "", "DEFAULT")
 		return []string{}, false
 	}
 
-	c.record(key, hasDefault, defStr, raw, source)
+	c.record(key, "[]string", hasDefault, defStr, raw, source)
 
 	items := strings.Split(str, sep)
 	for i, item := range items {
@@ -714,6 +1019,8 @@ func getNumber[T number](c *Configuration, key string, defaultValue ...T) (T, bo
 	raw, ok, source := c.getInternal(key)
 	str := strings.TrimPrefix(raw, "*EHE*")
 
+	typ := typeLabel[T]()
+
 	hasDefault := len(defaultValue) > 0
 	defStr := ""
 	if hasDefault {
@@ -722,15 +1029,15 @@ func getNumber[T number](c *Configuration, key string, defaultValue ...T) (T, bo
 
 	if str == "" || !ok {
 		if hasDefault {
-			c.record(key, hasDefault, defStr, defStr, "DEFAULT")
+			c.record(key, typ, hasDefault, defStr, defStr, "DEFAULT")
 			return defaultValue[0], false, nil
 		}
-		c.record(key, hasDefault, defStr, "", "DEFAULT")
+		c.record(key, typ, hasDefault, defStr, "", "DEFAULT")
 		var zero T
 		return zero, false, nil
 	}
 
-	c.record(key, hasDefault, defStr, raw, source)
+	c.record(key, typ, hasDefault, defStr, raw, source)
 
 	var result T
 	var err error
@@ -962,14 +1269,14 @@ func (c *Configuration) GetBool(key string, defaultValue ...bool) bool {
 
 	if str == "" || !ok {
 		if hasDefault {
-			c.record(key, hasDefault, defStr, defStr, "DEFAULT")
+			c.record(key, "bool", hasDefault, defStr, defStr, "DEFAULT")
 			return defaultValue[0]
 		}
-		c.record(key, hasDefault, defStr, "", "DEFAULT")
+		c.record(key, "bool", hasDefault, defStr, "", "DEFAULT")
 		return false
 	}
 
-	c.record(key, hasDefault, defStr, raw, source)
+	c.record(key, "bool", hasDefault, defStr, raw, source)
 
 	i, err := strconv.ParseBool(str)
 	if err != nil {
@@ -991,14 +1298,14 @@ func (c *Configuration) GetDuration(key string, defaultValue ...time.Duration) (
 
 	if str == "" || !ok {
 		if hasDefault {
-			c.record(key, hasDefault, defStr, defStr, "DEFAULT")
+			c.record(key, typeLabel[time.Duration](), hasDefault, defStr, defStr, "DEFAULT")
 			return defaultValue[0], nil, false
 		}
-		c.record(key, hasDefault, defStr, "", "DEFAULT")
+		c.record(key, typeLabel[time.Duration](), hasDefault, defStr, "", "DEFAULT")
 		return 0, nil, false
 	}
 
-	c.record(key, hasDefault, defStr, raw, source)
+	c.record(key, typeLabel[time.Duration](), hasDefault, defStr, raw, source)
 
 	d, err := time.ParseDuration(str)
 	if err != nil {
@@ -1022,13 +1329,13 @@ func (c *Configuration) GetURL(key string, defaultValue ...string) (*url.URL, er
 		if hasDefault {
 			str = defaultValue[0]
 			ok = false
-			c.record(key, hasDefault, defStr, str, "DEFAULT")
+			c.record(key, typeLabel[*url.URL](), hasDefault, defStr, str, "DEFAULT")
 		} else {
-			c.record(key, hasDefault, defStr, "", "DEFAULT")
+			c.record(key, typeLabel[*url.URL](), hasDefault, defStr, "", "DEFAULT")
 			return nil, errors.New("URL is missing"), false
 		}
 	} else {
-		c.record(key, hasDefault, defStr, str, source)
+		c.record(key, typeLabel[*url.URL](), hasDefault, defStr, str, source)
 	}
 
 	ehes := reEHE.FindAllString(str, -1)
@@ -1050,33 +1357,77 @@ func (c *Configuration) GetURL(key string, defaultValue ...string) (*url.URL, er
 	return u, nil, ok
 }
 
+// GetAll returns every declared setting with environment and layer overrides
+// applied.  When a value comes from a mapped environment variable, or one in a
+// .env file, the name of that variable is reported under the key
+// "_ENV:<key>".
 func (c *Configuration) GetAll() map[string]string {
+	m := c.unqualify(c.root().getAll())
+	for k, name := range c.unqualify(c.root().envNames()) {
+		m["_ENV:"+k] = name
+	}
+	m["_SETTINGS_CONTEXT"] = c.GetContext()
+
+	return m
+}
+
+func (c *Configuration) getAll() map[string]string {
+	mapping := c.getEnvMapping()
+	layers := c.getLayers()
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	m := make(map[string]string, 0)
 
-	m["_SETTINGS_CONTEXT"] = c.context
-
 	for k, v := range c.confs {
 		// Check if the key has a value in the environment
-		if envVal, ok := os.LookupEnv(k); ok {
+		if envVal, _, ok := mapping.lookupExact(k, c.dotenv); ok {
 			m[k] = envVal
 		} else {
 			m[k] = v
 		}
 	}
 
+	// Layers override the settings files, but the environment still wins
+	// for the layers below it
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		for _, k := range l.keys() {
+			if _, _, ok := mapping.lookupExact(k, c.dotenv); ok && l.priority < priorityEnv {
+				continue
+			}
+
+			v, _, _ := l.lookup([]string{k})
+			if l.secret {
+				v = eheMask
+			}
+			m[k] = v
+		}
+	}
+
 	return m
 }
 
 func (c *Configuration) requestedSnapshot() []requestRecord {
+	c = c.root()
+
 	c.rmu.RLock()
-	defer c.rmu.RUnlock()
 
 	rows := make([]requestRecord, 0, len(c.requests))
 	for _, rec := range c.requests {
-		rows = append(rows, *rec)
+		row := *rec
+		row.Callers = append([]string(nil), rec.Callers...)
+		rows = append(rows, row)
+	}
+	c.rmu.RUnlock()
+
+	// Values that interpolate a secret are recorded in plain text, so they
+	// are masked here
+	for i := range rows {
+		if c.interpolatesSecret(rows[i].Key) || (rows[i].Source == "DEFAULT" && c.refersToSecret(rows[i].DefaultValue)) {
+			rows[i].Value = eheMask
+		}
 	}
 
 	sort.Slice(rows, func(i, j int) bool {
@@ -1086,19 +1437,24 @@ func (c *Configuration) requestedSnapshot() []requestRecord {
 		if rows[i].HasDefault != rows[j].HasDefault {
 			return !rows[i].HasDefault
 		}
-		return rows[i].DefaultValue < rows[j].DefaultValue
+		if rows[i].DefaultValue != rows[j].DefaultValue {
+			return rows[i].DefaultValue < rows[j].DefaultValue
+		}
+		return rows[i].Type < rows[j].Type
 	})
 
 	return rows
 }
 
 func (c *Configuration) Requested() string {
+	c = c.root()
+
 	rows := c.requestedSnapshot()
 
 	var builder strings.Builder
 	w := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
 
-	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE\tDEFAULT\tFIRST\tLAST\tCOUNT")
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE\tDEFAULT\tFIRST\tLAST\tCOUNT\tCALLERS")
 
 	for _, r := range rows {
 		def := "-"
@@ -1106,7 +1462,7 @@ func (c *Configuration) Requested() string {
 			def = fmt.Sprintf("%q", r.DefaultValue)
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
 			r.Key,
 			r.Value,
 			r.Source,
@@ -1114,10 +1470,20 @@ func (c *Configuration) Requested() string {
 			r.FirstRequested.Format("2006-01-02 15:04:05.000"),
 			r.LastRequested.Format("2006-01-02 15:04:05.000"),
 			r.Count,
+			shortCallers(r.Callers),
 		)
 	}
 
 	_ = w.Flush()
+
+	conflicts := c.Conflicts()
+	if len(conflicts) > 0 {
+		builder.WriteString("\nWARNINGS\n--------\n")
+		for _, cf := range conflicts {
+			builder.WriteString(fmt.Sprintf("%s\n  at %s\n", cf, shortCallers(cf.Callers)))
+		}
+	}
+
 	return builder.String()
 }
 
@@ -1128,11 +1494,8 @@ type settingRow struct {
 }
 
 func (c *Configuration) settingsSnapshot() []settingRow {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
 	keysMap := make(map[string]struct{})
-	for item := range c.confs {
+	for _, item := range c.allKeys() {
 		keysMap[strings.Split(item, ".")[0]] = struct{}{}
 	}
 
@@ -1145,7 +1508,7 @@ func (c *Configuration) settingsSnapshot() []settingRow {
 	rows := make([]settingRow, 0, len(keysArr))
 	for _, k := range keysArr {
 		v, _, source := c.getInternal(k)
-		v = maskSecrets(v)
+		v = c.maskSetting(k, v, source)
 		rows = append(rows, settingRow{Key: k, Value: v, Source: source})
 	}
 
@@ -1165,6 +1528,8 @@ func (c *Configuration) requestCountByKey() map[string]int64 {
 }
 
 func (c *Configuration) Stats() string {
+	c = c.root()
+
 	var builder strings.Builder
 	builder.WriteString("\nCMDLINE\n")
 	builder.WriteString("-------\n")
@@ -1190,10 +1555,20 @@ func (c *Configuration) Stats() string {
 		builder.WriteString("Not set")
 	}
 
+	builder.WriteString("\nEnv files:   ")
+	if d := c.getDotenv(); d != nil && len(d.files) > 0 {
+		builder.WriteString(strings.Join(d.files, ", "))
+	} else {
+		builder.WriteString("None")
+	}
+
 	builder.WriteString("\n\nSETTINGS\n--------\n")
 
 	for _, row := range c.settingsSnapshot() {
-		context := strings.Replace(row.Source, row.Key, "", 1)
+		context := row.Source
+		if context == row.Key || strings.HasPrefix(context, row.Key+".") {
+			context = strings.TrimPrefix(context, row.Key)
+		}
 		if context != "" {
 			builder.WriteString(fmt.Sprintf("%s[%s]=%s\n", row.Key, context, row.Value))
 		} else {
@@ -1206,10 +1581,14 @@ func (c *Configuration) Stats() string {
 
 // Get context
 func (c *Configuration) GetContext() string {
+	c = c.root()
+
 	return c.context
 }
 
 func (c *Configuration) AddListener(listener SettingsListener) {
+	c = c.root()
+
 	c.listenerMu.Lock()
 	defer c.listenerMu.Unlock()
 
@@ -1217,6 +1596,8 @@ func (c *Configuration) AddListener(listener SettingsListener) {
 }
 
 func (c *Configuration) RemoveListener(listener SettingsListener) {
+	c = c.root()
+
 	c.listenerMu.Lock()
 	defer c.listenerMu.Unlock()
 
@@ -1237,6 +1618,8 @@ func (c *Configuration) printConfigHTML(p io.Writer) {
 	settings := c.settingsSnapshot()
 	counts := c.requestCountByKey()
 	requested := c.requestedSnapshot()
+	conflicts := c.Conflicts()
+	deprecated := c.Deprecations()
 
 	fmt.Fprintf(p, `<html>
 <head>
@@ -1247,17 +1630,50 @@ func (c *Configuration) printConfigHTML(p io.Writer) {
 <script type='text/javascript'>
 $(document).ready(function() {
 	$('#settingsTable').tablesorter({ sortList: [[3,1]], widgets: ['zebra', 'saveSort'], headers: { 0: {sorter:'text'}, 1: {sorter:'text'}, 2: {sorter:'text'}, 3: {sorter:'number'} }, widgetOptions: { saveSort: true } });
-	$('#requestedTable').tablesorter({ sortList: [[0,0]], widgets: ['zebra', 'saveSort'], headers: { 0: {sorter:'text'}, 1: {sorter:'text'}, 2: {sorter:'text'}, 3: {sorter:'text'}, 4: {sorter:'usLongDate'}, 5: {sorter:'usLongDate'}, 6: {sorter:'number'} }, widgetOptions: { saveSort: true } });
+	$('#coverageTable').tablesorter({ sortList: [[1,0],[0,0]], widgets: ['zebra'] });
+	$('#requestedTable').tablesorter({ sortList: [[0,0]], widgets: ['zebra', 'saveSort'], headers: { 0: {sorter:'text'}, 1: {sorter:'text'}, 2: {sorter:'text'}, 3: {sorter:'text'}, 4: {sorter:'usLongDate'}, 5: {sorter:'usLongDate'}, 6: {sorter:'number'}, 7: {sorter:'text'} }, widgetOptions: { saveSort: true } });
 });
 </script>
 </head>
 <body>
 <h1>GoCore Configuration</h1>
-<h2>Settings</h2>
+<p><a href='%sconfig/docs'>Settings reference</a> | <a href='%sconfig.json'>JSON</a></p>
+`, statPrefix, statPrefix, statPrefix)
+
+	if c.editEnabled() {
+		c.printEditHTML(p)
+	}
+
+	if len(conflicts) > 0 || len(deprecated) > 0 {
+		fmt.Fprintf(p, "<h2>Warnings</h2>\r\n")
+	}
+
+	if len(conflicts) > 0 {
+		fmt.Fprintf(p, "<ul id='conflicts'>\r\n")
+		for _, cf := range conflicts {
+			fmt.Fprintf(p, "<li>%s<br/>at %s</li>\r\n", html.EscapeString(cf.String()), html.EscapeString(shortCallers(cf.Callers)))
+		}
+		fmt.Fprintf(p, "</ul>\r\n")
+	}
+
+	if len(deprecated) > 0 {
+		fmt.Fprintf(p, "<ul id='deprecated'>\r\n")
+		for _, d := range deprecated {
+			fmt.Fprintf(p, "<li>%s is deprecated, use %s instead (set in %s). %s</li>\r\n",
+				html.EscapeString(d.OldKey),
+				html.EscapeString(d.NewKey),
+				html.EscapeString(d.Source),
+				html.EscapeString(d.Message),
+			)
+		}
+		fmt.Fprintf(p, "</ul>\r\n")
+	}
+
+	fmt.Fprintf(p, `<h2>Settings</h2>
 <table id='settingsTable' class='tablesorter' border='0' cellpadding='0' cellspacing='1'>
 <thead><tr><th>Key</th><th>Value</th><th>Source</th><th>Requests</th></tr></thead>
 <tbody>
-`, statPrefix)
+`)
 
 	for _, s := range settings {
 		fmt.Fprintf(p, "<tr><td>%s</td><td>%s</td><td>%s</td><td align='right'>%d</td></tr>\r\n",
@@ -1272,7 +1688,7 @@ $(document).ready(function() {
 </table>
 <h2>Requested</h2>
 <table id='requestedTable' class='tablesorter' border='0' cellpadding='0' cellspacing='1'>
-<thead><tr><th>Key</th><th>Value</th><th>Source</th><th>Default</th><th>First</th><th>Last</th><th>Count</th></tr></thead>
+<thead><tr><th>Key</th><th>Value</th><th>Source</th><th>Default</th><th>First</th><th>Last</th><th>Count</th><th>Callers</th></tr></thead>
 <tbody>
 `)
 
@@ -1282,7 +1698,7 @@ $(document).ready(function() {
 			def = rq.DefaultValue
 		}
 
-		fmt.Fprintf(p, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td align='right'>%d</td></tr>\r\n",
+		fmt.Fprintf(p, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td align='right'>%d</td><td>%s</td></tr>\r\n",
 			html.EscapeString(rq.Key),
 			html.EscapeString(rq.Value),
 			html.EscapeString(rq.Source),
@@ -1290,6 +1706,28 @@ $(document).ready(function() {
 			rq.FirstRequested.Format("2006-01-02 15:04:05.000"),
 			rq.LastRequested.Format("2006-01-02 15:04:05.000"),
 			rq.Count,
+			html.EscapeString(shortCallers(rq.Callers)),
+		)
+	}
+
+	fmt.Fprintf(p, "</tbody>\r\n</table>\r\n")
+
+	coverage := c.Coverage()
+
+	fmt.Fprintf(p, "<h2>Coverage</h2>\r\n<table id='coverageTable' class='tablesorter' border='0' cellpadding='0' cellspacing='1'>\r\n")
+	fmt.Fprintf(p, "<thead><tr><th>Key</th><th>Status</th><th>Sources</th></tr></thead>\r\n<tbody>\r\n")
+
+	for _, e := range coverage.Unused {
+		fmt.Fprintf(p, "<tr><td>%s</td><td>Declared but never requested</td><td>%s</td></tr>\r\n",
+			html.EscapeString(e.Key),
+			html.EscapeString(shortCallers(e.Sources)),
+		)
+	}
+
+	for _, e := range coverage.Undeclared {
+		fmt.Fprintf(p, "<tr><td>%s</td><td>Requested but not declared</td><td>%s</td></tr>\r\n",
+			html.EscapeString(e.Key),
+			html.EscapeString(shortCallers(e.Sources)),
 		)
 	}
 
@@ -0,0 +1,172 @@
+package gocore
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// alias maps a deprecated key to its replacement.
+type alias struct {
+	oldKey  string
+	newKey  string
+	message string
+	used    atomic.Bool
+	warned  sync.Once
+}
+
+type aliasSet struct {
+	mu    sync.RWMutex
+	byNew map[string]*alias
+}
+
+// DeprecatedKey describes a deprecated key that still has a value.
+type DeprecatedKey struct {
+	OldKey  string
+	NewKey  string
+	Message string
+	Source  string
+	Used    bool
+}
+
+// Alias registers oldKey as a deprecated name for newKey.  When newKey has no
+// value, Get(newKey) and the typed getters fall back to oldKey, resolved with
+// the usual context and application fallback, and a deprecation warning is
+// logged the first time this happens.
+func (c *Configuration) Alias(oldKey, newKey, deprecationMessage string) {
+	c, oldKey, newKey = c.root(), c.qualify(oldKey), c.qualify(newKey)
+
+	c.mu.Lock()
+	if c.aliases == nil {
+		c.aliases = &aliasSet{byNew: make(map[string]*alias)}
+	}
+	aliases := c.aliases
+	c.mu.Unlock()
+
+	aliases.mu.Lock()
+	defer aliases.mu.Unlock()
+
+	aliases.byNew[newKey] = &alias{
+		oldKey:  oldKey,
+		newKey:  newKey,
+		message: deprecationMessage,
+	}
+}
+
+// clone returns a copy of the set with no record of which aliases have been
+// used.
+func (s *aliasSet) clone() *aliasSet {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cp := &aliasSet{byNew: make(map[string]*alias, len(s.byNew))}
+	for k, a := range s.byNew {
+		cp.byNew[k] = &alias{oldKey: a.oldKey, newKey: a.newKey, message: a.message}
+	}
+
+	return cp
+}
+
+func (c *Configuration) getAlias(newKey string) *alias {
+	c.mu.RLock()
+	aliases := c.aliases
+	c.mu.RUnlock()
+
+	if aliases == nil {
+		return nil
+	}
+
+	aliases.mu.RLock()
+	defer aliases.mu.RUnlock()
+
+	return aliases.byNew[newKey]
+}
+
+// resolveAliased is resolve for a key that may have a deprecated alias.  The
+// old key is tried at each level of the context fallback straight after the
+// new one, so that old.live is preferred to a plain new key in live, and the
+// deprecation warning is logged the first time the old key supplies a value.
+func (c *Configuration) resolveAliased(key string) (string, bool, string) {
+	a := c.getAlias(key)
+	if a == nil {
+		return c.resolve(key)
+	}
+
+	newKeys := c.candidateKeys(key)
+	oldKeys := c.candidateKeys(a.oldKey)
+
+	// Both lists start with the key followed by each context suffix, after
+	// which they fall back to the parents of the key itself.
+	levels := len(c.candidateKeys(""))
+
+	candidates := make([]string, 0, len(newKeys)+len(oldKeys))
+	for i := 0; i < levels; i++ {
+		candidates = append(candidates, newKeys[i], oldKeys[i])
+	}
+	candidates = append(candidates, newKeys[levels:]...)
+	candidates = append(candidates, oldKeys[levels:]...)
+
+	val, source, keyUsed, ok := c.resolveKeys(key, candidates, []string{key, a.oldKey})
+	if !ok {
+		return "", false, ""
+	}
+
+	if slices.Contains(oldKeys, keyUsed) && !slices.Contains(newKeys, keyUsed) {
+		a.used.Store(true)
+		a.warned.Do(func() {
+			logWarnf("WARN: Setting %q is deprecated, use %q instead. %s", a.oldKey, a.newKey, a.message)
+		})
+	}
+
+	// Replace variables in the value
+	return c.decrypt(c.replaceVariables(val)), true, source
+}
+
+// Deprecations returns the deprecated keys that still have a value in the
+// settings files, the environment or any other layer.
+func (c *Configuration) Deprecations() []DeprecatedKey {
+	c = c.root()
+
+	c.mu.RLock()
+	aliases := c.aliases
+	c.mu.RUnlock()
+
+	if aliases == nil {
+		return nil
+	}
+
+	aliases.mu.RLock()
+	all := make([]*alias, 0, len(aliases.byNew))
+	for _, a := range aliases.byNew {
+		all = append(all, a)
+	}
+	aliases.mu.RUnlock()
+
+	deprecated := make([]DeprecatedKey, 0)
+
+	for _, a := range all {
+		_, ok, source := c.resolve(a.oldKey)
+		if !ok {
+			continue
+		}
+
+		deprecated = append(deprecated, DeprecatedKey{
+			OldKey:  a.oldKey,
+			NewKey:  a.newKey,
+			Message: a.message,
+			Source:  source,
+			Used:    a.used.Load(),
+		})
+	}
+
+	sort.Slice(deprecated, func(i, j int) bool {
+		return deprecated[i].OldKey < deprecated[j].OldKey
+	})
+
+	return deprecated
+}
@@ -0,0 +1,88 @@
+package gocore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlias(t *testing.T) {
+	cfg := newTestConfig(t, "live", "db_host = old-host\ndb_host.live = old-live-host\ndb_port = 1\ndatabase_port = 2\n")
+
+	cfg.Alias("db_host", "database_host", "Renamed in v2.")
+	cfg.Alias("db_port", "database_port", "Renamed in v2.")
+	cfg.Alias("db_user", "database_user", "Renamed in v2.")
+
+	v, ok := cfg.Get("database_host")
+	assert.True(t, ok)
+	assert.Equal(t, "old-live-host", v)
+
+	// The new key wins when both are set
+	n, ok := cfg.GetInt("database_port")
+	assert.True(t, ok)
+	assert.Equal(t, 2, n)
+
+	v, ok = cfg.Get("database_user", "nobody")
+	assert.False(t, ok)
+	assert.Equal(t, "nobody", v)
+
+	deprecated := cfg.Deprecations()
+	require.Len(t, deprecated, 2)
+	assert.Equal(t, "db_host", deprecated[0].OldKey)
+	assert.Equal(t, "database_host", deprecated[0].NewKey)
+	assert.Equal(t, "db_host.live", deprecated[0].Source)
+	assert.True(t, deprecated[0].Used)
+	assert.Equal(t, "db_port", deprecated[1].OldKey)
+	assert.False(t, deprecated[1].Used)
+}
+
+func TestAliasContextOrder(t *testing.T) {
+	cfg := newTestConfig(t, "live", `database_host = new-host
+db_host.live = old-live-host
+database_port = 1
+database_port.live = 2
+db_port.live = 3
+`)
+
+	cfg.Alias("db_host", "database_host", "")
+	cfg.Alias("db_port", "database_port", "")
+
+	// A context specific old key is preferred to the plain new key
+	v, ok := cfg.Get("database_host")
+	assert.True(t, ok)
+	assert.Equal(t, "old-live-host", v)
+
+	// and the new key wins at the same level
+	v, ok = cfg.Get("database_port")
+	assert.True(t, ok)
+	assert.Equal(t, "2", v)
+
+	deprecated := cfg.Deprecations()
+	require.Len(t, deprecated, 2)
+	assert.True(t, deprecated[0].Used)
+	assert.False(t, deprecated[1].Used)
+}
+
+func TestAliasNoLoop(t *testing.T) {
+	cfg := newTestConfig(t, "", "")
+
+	cfg.Alias("loop_a", "loop_b", "")
+	cfg.Alias("loop_b", "loop_a", "")
+
+	_, ok := cfg.Get("loop_a")
+	assert.False(t, ok)
+}
+
+func TestHandleConfigDeprecated(t *testing.T) {
+	Config().Set("alias_old_key", "x")
+	Config().Alias("alias_old_key", "alias_new_key", "Use alias_new_key.")
+
+	rec := httptest.NewRecorder()
+	HandleConfig(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+
+	assert.Contains(t, rec.Body.String(), "id='deprecated'")
+	assert.Contains(t, rec.Body.String(), "alias_old_key is deprecated, use alias_new_key instead")
+}
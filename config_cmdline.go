@@ -0,0 +1,116 @@
+package gocore
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseSettingsArgs extracts settings overrides from command line arguments.
+// It recognises the following forms, which may be repeated:
+//
+//	--set key=value
+//	--set=key=value
+//	--settings-file path
+//	--settings-file=path
+//
+// Arguments are applied in order, so a later --set overrides an earlier
+// --settings-file entry for the same key.  Parsing stops at a -- terminator,
+// as it does in the flag package.  The remaining arguments, including the
+// terminator and everything after it, are returned so that they can be passed
+// on to the flag package.
+func ParseSettingsArgs(args []string) (map[string]string, []string, error) {
+	settings := make(map[string]string)
+	rest := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		if name != "--set" && name != "-set" && name != "--settings-file" && name != "-settings-file" {
+			rest = append(rest, arg)
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("missing value for %s", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch strings.TrimLeft(name, "-") {
+		case "set":
+			key, val, ok := strings.Cut(value, "=")
+			key = strings.TrimSpace(key)
+			if !ok || key == "" {
+				return nil, nil, fmt.Errorf("invalid %s %q, expected key=value", name, value)
+			}
+			settings[key] = val
+
+		case "settings-file":
+			b, err := os.ReadFile(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read settings file %q: %w", value, err)
+			}
+			if err := parseSettings(settings, nil, value, string(b)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return settings, rest, nil
+}
+
+// SetCommandLineSettings installs settings as the CMDLINE layer, replacing any
+// previous command line settings.  Applications that parse their own flags can
+// use this instead of relying on gocore reading os.Args.
+func (c *Configuration) SetCommandLineSettings(settings map[string]string) {
+	c = c.root()
+
+	values := make(map[string]string, len(settings))
+	for k, v := range settings {
+		values[k] = v
+	}
+
+	c.replaceLayer(sourceCmdline, c.cmdlinePriority(), false, values)
+}
+
+// SetCommandLinePrecedence controls whether command line settings override
+// the environment (the default) or are overridden by it.
+func (c *Configuration) SetCommandLinePrecedence(aboveEnv bool) {
+	c = c.root()
+
+	c.changeMu.Lock()
+	defer c.changeMu.Unlock()
+
+	c.mu.Lock()
+	c.cmdlineBelowEnv = !aboveEnv
+	c.mu.Unlock()
+
+	for _, existing := range c.getLayers() {
+		if existing.name == sourceCmdline {
+			l := existing.clone()
+			l.priority = c.cmdlinePriority()
+			c.addLayer(l)
+			c.generation.Add(1)
+		}
+	}
+}
+
+func (c *Configuration) cmdlinePriority() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.cmdlineBelowEnv {
+		return priorityCmdlineLow
+	}
+
+	return priorityCmdlineHigh
+}
@@ -0,0 +1,65 @@
+package gocore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSettingsArgs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "override.conf")
+	require.NoError(t, os.WriteFile(file, []byte("a=from-file\nb=from-file # comment\n"), 0600))
+
+	settings, rest, err := ParseSettingsArgs([]string{
+		"-v",
+		"--settings-file", file,
+		"--set", "a=from-set",
+		"--set=url.live=https://example.com?x=1",
+		"positional",
+		"--",
+		"--set", "a=after-terminator",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"-v", "positional", "--", "--set", "a=after-terminator"}, rest)
+	assert.Equal(t, map[string]string{
+		"a":        "from-set",
+		"b":        "from-file",
+		"url.live": "https://example.com?x=1",
+	}, settings)
+
+	_, _, err = ParseSettingsArgs([]string{"--set", "novalue"})
+	assert.Error(t, err)
+
+	_, _, err = ParseSettingsArgs([]string{"--set"})
+	assert.Error(t, err)
+}
+
+func TestCommandLineLayer(t *testing.T) {
+	cfg := newTestConfig(t, "live", "url = http://localhost\nurl.live = https://live\ncmd_env_key = file\n")
+
+	cfg.SetCommandLineSettings(map[string]string{"url.live": "https://cmdline", "cmd_env_key": "cmdline"})
+
+	v, _ := cfg.Get("url")
+	assert.Equal(t, "https://cmdline", v)
+
+	t.Setenv("cmd_env_key", "env")
+
+	v, _ = cfg.Get("cmd_env_key")
+	assert.Equal(t, "cmdline", v)
+
+	cfg.SetCommandLinePrecedence(false)
+
+	v, _ = cfg.Get("cmd_env_key")
+	assert.Equal(t, "env", v)
+
+	sources := make(map[string]string)
+	for _, r := range cfg.requestedSnapshot() {
+		sources[r.Key] = r.Source
+	}
+	assert.Equal(t, "CMDLINE:url.live", sources["url"])
+	assert.Equal(t, "ENV", sources["cmd_env_key"])
+}
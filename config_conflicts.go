@@ -0,0 +1,172 @@
+package gocore
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// maxCallers limits how many distinct call sites are kept per request record.
+const maxCallers = 10
+
+var configFramePrefix = reflect.TypeOf(Configuration{}).PkgPath() + "."
+
+// configFrameNames are the functions in this package that sit between a
+// caller and record.
+var configFrameNames = []string{"(*Configuration).", "Snapshot.", "(*FeatureFlag).", "getNumber[", "getParsed[", "GetAs[", "TryGetAs["}
+
+// requestCaller returns the file:line of the code that asked for a setting,
+// skipping the Configuration methods and getter helpers in between.
+func requestCaller() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isConfigFrame(frame.Function) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func isConfigFrame(function string) bool {
+	if !strings.HasPrefix(function, configFramePrefix) {
+		return false
+	}
+
+	name := strings.TrimPrefix(function, configFramePrefix)
+
+	for _, prefix := range configFrameNames {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *requestRecord) addCaller(caller string) {
+	if caller == "" || len(r.Callers) >= maxCallers {
+		return
+	}
+
+	for _, existing := range r.Callers {
+		if existing == caller {
+			return
+		}
+	}
+
+	r.Callers = append(r.Callers, caller)
+	sort.Strings(r.Callers)
+}
+
+// Conflict describes a key that has been requested with different default
+// values, or through getters of different types, at different call sites.
+type Conflict struct {
+	Key      string
+	Defaults []string
+	Types    []string
+	Callers  []string
+}
+
+func (cf Conflict) String() string {
+	var reasons []string
+
+	if len(cf.Defaults) > 1 {
+		reasons = append(reasons, fmt.Sprintf("defaults %s", strings.Join(quoteAll(cf.Defaults), ", ")))
+	}
+
+	if len(cf.Types) > 1 {
+		reasons = append(reasons, fmt.Sprintf("types %s", strings.Join(cf.Types, ", ")))
+	}
+
+	return fmt.Sprintf("%s requested with different %s", cf.Key, strings.Join(reasons, " and "))
+}
+
+// Conflicts returns every key that has been requested with more than one
+// default value or through more than one typed getter.  Plain string Get
+// calls are not counted as a distinct type, as any setting can be read as a
+// string.
+func (c *Configuration) Conflicts() []Conflict {
+	c = c.root()
+
+	byKey := make(map[string][]requestRecord)
+	for _, r := range c.requestedSnapshot() {
+		byKey[r.Key] = append(byKey[r.Key], r)
+	}
+
+	conflicts := make([]Conflict, 0)
+
+	for key, rows := range byKey {
+		defaults := make(map[string]struct{})
+		types := make(map[string]struct{})
+		callers := make(map[string]struct{})
+
+		for _, r := range rows {
+			if r.HasDefault {
+				defaults[r.DefaultValue] = struct{}{}
+			}
+			if r.Type != "string" {
+				types[r.Type] = struct{}{}
+			}
+			for _, caller := range r.Callers {
+				callers[caller] = struct{}{}
+			}
+		}
+
+		if len(defaults) < 2 && len(types) < 2 {
+			continue
+		}
+
+		conflicts = append(conflicts, Conflict{
+			Key:      key,
+			Defaults: sortedKeys(defaults),
+			Types:    sortedKeys(types),
+			Callers:  sortedKeys(callers),
+		})
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Key < conflicts[j].Key
+	})
+
+	return conflicts
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+
+	return quoted
+}
+
+// shortCallers trims each caller down to its file name and line for display.
+func shortCallers(callers []string) string {
+	short := make([]string, len(callers))
+	for i, caller := range callers {
+		if pos := strings.LastIndex(caller, "/"); pos != -1 {
+			caller = caller[pos+1:]
+		}
+		short[i] = caller
+	}
+
+	return strings.Join(short, ", ")
+}
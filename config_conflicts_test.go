@@ -0,0 +1,84 @@
+package gocore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestRecordsCaller(t *testing.T) {
+	Config().Get("caller_key")
+
+	for _, r := range Config().requestedSnapshot() {
+		if r.Key == "caller_key" {
+			require.Len(t, r.Callers, 1)
+			assert.True(t, strings.Contains(r.Callers[0], "config_conflicts_test.go:"), r.Callers[0])
+			return
+		}
+	}
+	t.Fatal("caller_key was not recorded")
+}
+
+func TestRequestCallersSampled(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "")
+
+	for i := 0; i < 100; i++ {
+		cfg.Get("sampled")
+	}
+
+	callers := func() []string {
+		rows := cfg.requestedSnapshot()
+		require.Len(t, rows, 1)
+		return rows[0].Callers
+	}
+
+	// The only call site was found on the first request
+	assert.Len(t, callers(), 1)
+
+	// Another call site is found when it makes a sampled request, here the
+	// 128th
+	for i := 0; i < 28; i++ {
+		cfg.Get("sampled")
+	}
+	assert.Len(t, callers(), 2)
+}
+
+func TestConflicts(t *testing.T) {
+	Config().Get("conflict_timeout", "5s")
+	Config().Get("conflict_timeout", "30s")
+
+	Config().GetInt("conflict_typed", 5)
+	_, _, _ = Config().GetDuration("conflict_typed", 5*time.Second)
+
+	Config().Get("conflict_none", "x")
+	Config().Get("conflict_none", "x")
+	Config().GetInt("conflict_string_ok", 1)
+	Config().Get("conflict_string_ok")
+
+	found := make(map[string]Conflict)
+	for _, cf := range Config().Conflicts() {
+		found[cf.Key] = cf
+	}
+
+	require.Contains(t, found, "conflict_timeout")
+	assert.Equal(t, []string{"30s", "5s"}, found["conflict_timeout"].Defaults)
+	assert.Len(t, found["conflict_timeout"].Callers, 2)
+
+	require.Contains(t, found, "conflict_typed")
+	assert.Equal(t, []string{"duration", "int"}, found["conflict_typed"].Types)
+
+	assert.NotContains(t, found, "conflict_none")
+	assert.NotContains(t, found, "conflict_string_ok")
+
+	assert.Contains(t, Config().Requested(), `conflict_timeout requested with different defaults "30s", "5s"`)
+
+	rec := httptest.NewRecorder()
+	HandleConfig(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+	assert.Contains(t, rec.Body.String(), "id='conflicts'")
+	assert.Contains(t, rec.Body.String(), "and types duration, int")
+}
@@ -0,0 +1,221 @@
+package gocore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// internalKeys are read by gocore itself, so they are left out of coverage
+// reports whether or not an application declares them.  So are the feature
+// flags under flagPrefix, which are read without being recorded.
+var internalKeys = map[string]struct{}{
+	"remoteConfigURL":          {},
+	"remoteConfigService":      {},
+	"remoteConfigCacheFile":    {},
+	"remoteConfigPollInterval": {},
+	"settingsCoverageLogDelay": {},
+	"secretsPollInterval":      {},
+	"advertisingURL":           {},
+	"advertisingInterval":      {},
+	"configEditToken":          {},
+	"configEditUser":           {},
+	"configEditPassword":       {},
+}
+
+// isInternalKey reports whether key, or the key it is a variant of, is one of
+// gocore's own settings.
+func isInternalKey(key string) bool {
+	if strings.HasPrefix(key, flagPrefix) {
+		return true
+	}
+
+	_, found := internalKeys[strings.Split(key, ".")[0]]
+
+	return found
+}
+
+// CoverageEntry is a key in a coverage report together with where it was
+// declared (file:line) or, for undeclared keys, where it was requested.
+type CoverageEntry struct {
+	Key     string
+	Sources []string
+}
+
+// Coverage compares the keys declared in the settings files with the keys
+// the application has requested.
+type Coverage struct {
+	// Unused keys are declared in a settings file but have never been
+	// requested, either directly or through ${} interpolation.
+	Unused []CoverageEntry
+	// Undeclared keys have been requested but are not declared in any
+	// settings file.
+	Undeclared []CoverageEntry
+}
+
+// Coverage returns the declared-but-unused and requested-but-undeclared keys.
+// A request for url covers url.live and url.live.uk as well.  Unused keys are
+// reported exactly, so if kafka.brokers is requested and kafka.topic is not,
+// only kafka.topic is unused.  gocore's own settings, such as remoteConfigURL
+// and the feature flags, are not reported.
+func (c *Configuration) Coverage() Coverage {
+	c = c.root()
+
+	requested := make(map[string][]string)
+	for _, r := range c.requestedSnapshot() {
+		if isInternalKey(r.Key) {
+			continue
+		}
+		requested[r.Key] = append(requested[r.Key], r.Callers...)
+	}
+
+	c.mu.RLock()
+	declared := make(map[string][]string)
+	referenced := make(map[string]struct{})
+	for k, v := range c.confs {
+		for _, match := range reVariable.FindAllStringSubmatch(v, -1) {
+			referenced[match[1]] = struct{}{}
+		}
+
+		if isInternalKey(k) {
+			continue
+		}
+
+		declared[k] = nil
+		if origin, found := c.origins[k]; found {
+			declared[k] = []string{origin}
+		}
+	}
+	c.mu.RUnlock()
+
+	isUsed := func(key string) bool {
+		for k := key; ; {
+			if _, found := requested[k]; found {
+				return true
+			}
+			if _, found := referenced[k]; found {
+				return true
+			}
+
+			pos := strings.LastIndex(k, ".")
+			if pos == -1 {
+				return false
+			}
+			k = k[:pos]
+		}
+	}
+
+	unused := make(map[string][]string)
+	for k, origins := range declared {
+		if !isUsed(k) {
+			unused[k] = origins
+		}
+	}
+
+	undeclared := make(map[string][]string)
+	for k, callers := range requested {
+		isDeclared := false
+		for d := range declared {
+			if d == k || strings.HasPrefix(d, k+".") {
+				isDeclared = true
+				break
+			}
+		}
+
+		if !isDeclared {
+			undeclared[k] = callers
+		}
+	}
+
+	return Coverage{
+		Unused:     coverageEntries(unused),
+		Undeclared: coverageEntries(undeclared),
+	}
+}
+
+func coverageEntries(m map[string][]string) []CoverageEntry {
+	entries := make([]CoverageEntry, 0, len(m))
+
+	for k, sources := range m {
+		set := make(map[string]struct{}, len(sources))
+		for _, s := range sources {
+			set[s] = struct{}{}
+		}
+
+		entries = append(entries, CoverageEntry{Key: k, Sources: sortedKeys(set)})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	return entries
+}
+
+// groupByRoot groups entries by their root key, in the order of the roots.
+func groupByRoot(entries []CoverageEntry) [][]CoverageEntry {
+	byRoot := make(map[string][]CoverageEntry)
+	for _, e := range entries {
+		root := strings.Split(e.Key, ".")[0]
+		byRoot[root] = append(byRoot[root], e)
+	}
+
+	roots := make([]string, 0, len(byRoot))
+	for root := range byRoot {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	groups := make([][]CoverageEntry, 0, len(roots))
+	for _, root := range roots {
+		groups = append(groups, byRoot[root])
+	}
+
+	return groups
+}
+
+func (cv Coverage) String() string {
+	var builder strings.Builder
+
+	write := func(title string, entries []CoverageEntry) {
+		builder.WriteString(title + "\n")
+		builder.WriteString(strings.Repeat("-", len(title)) + "\n")
+
+		if len(entries) == 0 {
+			builder.WriteString("None\n")
+			return
+		}
+
+		w := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+		for _, group := range groupByRoot(entries) {
+			// Keys are listed under their root key when there is more than
+			// one of them, such as url and url.live
+			if len(group) == 1 {
+				fmt.Fprintf(w, "%s\t%s\n", group[0].Key, shortCallers(group[0].Sources))
+				continue
+			}
+
+			fmt.Fprintf(w, "%s\n", strings.Split(group[0].Key, ".")[0])
+			for _, e := range group {
+				fmt.Fprintf(w, "  %s\t%s\n", e.Key, shortCallers(e.Sources))
+			}
+		}
+		_ = w.Flush()
+	}
+
+	write("UNUSED (declared but never requested)", cv.Unused)
+	builder.WriteString("\n")
+	write("UNDECLARED (requested but not declared)", cv.Undeclared)
+
+	return builder.String()
+}
+
+// logCoverageAfter logs the coverage report once the application has had
+// time to request its settings.
+func (c *Configuration) logCoverageAfter(delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		logInfof("INFO: Settings coverage after %s\n%s", delay, c.Coverage())
+	})
+}
@@ -0,0 +1,50 @@
+package gocore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverage(t *testing.T) {
+	cfg := newTestConfig(t, "live", strings.Join([]string{
+		"url = http://localhost",
+		"url.live = https://live",
+		"dead = 1",
+		"dead.live = 2",
+		"greeting = hello ${who}",
+		"who = world",
+		"advertisingURL.live = http://advertise",
+		"kafka.brokers = k1,k2",
+		"kafka.topic = events",
+		"flag.newui = 50%",
+	}, "\n"))
+
+	cfg.Get("url")
+	cfg.Get("greeting")
+	cfg.Get("undeclared_key", "x")
+	cfg.Get("advertisingInterval")
+	cfg.Get("remoteConfigURL")
+	cfg.Get("kafka.brokers")
+
+	cv := cfg.Coverage()
+
+	assert.Equal(t, []CoverageEntry{
+		{Key: "dead", Sources: []string{"settings.conf:3"}},
+		{Key: "dead.live", Sources: []string{"settings.conf:4"}},
+		{Key: "kafka.topic", Sources: []string{"settings.conf:9"}},
+	}, cv.Unused)
+
+	require.Len(t, cv.Undeclared, 1)
+	assert.Equal(t, "undeclared_key", cv.Undeclared[0].Key)
+	require.Len(t, cv.Undeclared[0].Sources, 1)
+	assert.Contains(t, cv.Undeclared[0].Sources[0], "config_coverage_test.go:")
+
+	out := cv.String()
+	assert.Contains(t, out, "UNUSED (declared but never requested)")
+	assert.Contains(t, out, "dead\n  dead       settings.conf:3\n  dead.live  settings.conf:4\n")
+	assert.Contains(t, out, "kafka.topic  settings.conf:9\n")
+	assert.Contains(t, out, "UNDECLARED (requested but not declared)")
+}
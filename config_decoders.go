@@ -0,0 +1,108 @@
+package gocore
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = make(map[reflect.Type]interface{})
+)
+
+// typeLabels are the names that requests for these types are recorded under.
+// The typed getters and GetAs share them, so that reading a key through both
+// is not reported as a conflict.  Other types are recorded under their Go
+// type name, such as int or []string.
+var typeLabels = map[reflect.Type]string{
+	reflect.TypeOf(time.Duration(0)):      "duration",
+	reflect.TypeOf(time.Time{}):           "time",
+	reflect.TypeOf(net.IP(nil)):           "ip",
+	reflect.TypeOf((*net.IPNet)(nil)):     "cidr",
+	reflect.TypeOf((*regexp.Regexp)(nil)): "regexp",
+	reflect.TypeOf((*url.URL)(nil)):       "url",
+}
+
+// typeLabel returns the name that requests for T are recorded under.
+func typeLabel[T any]() string {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if label, found := typeLabels[t]; found {
+		return label
+	}
+
+	return t.String()
+}
+
+func init() {
+	RegisterDecoder(func(s string) (string, error) { return s, nil })
+	RegisterDecoder(strconv.ParseBool)
+	RegisterDecoder(strconv.Atoi)
+	RegisterDecoder(func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) })
+	RegisterDecoder(func(s string) (uint64, error) { return strconv.ParseUint(s, 10, 64) })
+	RegisterDecoder(func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+	RegisterDecoder(time.ParseDuration)
+	RegisterDecoder(func(s string) (time.Time, error) { return time.Parse(time.RFC3339, s) })
+	RegisterDecoder(parseIP)
+	RegisterDecoder(func(s string) (*net.IPNet, error) {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	})
+	RegisterDecoder(regexp.Compile)
+}
+
+// RegisterDecoder makes T available to GetAs and TryGetAs.  Registering a
+// decoder for a type that already has one replaces it.
+//
+//	gocore.RegisterDecoder(func(s string) (LogLevel, error) {
+//		return ParseLogLevel(s)
+//	})
+func RegisterDecoder[T any](decode func(string) (T, error)) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+
+	decoders[reflect.TypeOf((*T)(nil)).Elem()] = decode
+}
+
+func getDecoder[T any]() (func(string) (T, error), bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+
+	decode, found := decoders[reflect.TypeOf((*T)(nil)).Elem()]
+	if !found {
+		return nil, false
+	}
+
+	return decode.(func(string) (T, error)), true
+}
+
+// TryGetAs returns the setting decoded by the decoder registered for T.  It
+// behaves like TryGetInt: a missing setting returns the default, if any, and
+// a value that fails to decode returns an error.
+func TryGetAs[T any](c *Configuration, key string, defaultValue ...T) (T, bool, error) {
+	decode, found := getDecoder[T]()
+	if !found {
+		var zero T
+		return zero, false, fmt.Errorf("no decoder registered for %T", zero)
+	}
+
+	return getParsed(c, key, typeLabel[T](), decode, func(v T) string {
+		return fmt.Sprintf("%v", v)
+	}, defaultValue...)
+}
+
+// GetAs is TryGetAs without the error: a value that fails to decode returns
+// false, as GetInt does.
+func GetAs[T any](c *Configuration, key string, defaultValue ...T) (T, bool) {
+	v, found, err := TryGetAs(c, key, defaultValue...)
+	if err != nil {
+		return v, false
+	}
+
+	return v, found
+}
@@ -0,0 +1,110 @@
+package gocore
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLogLevel int
+
+const (
+	testLevelDebug testLogLevel = iota
+	testLevelInfo
+	testLevelWarn
+)
+
+func (l testLogLevel) String() string {
+	return [...]string{"debug", "info", "warn"}[l]
+}
+
+func parseTestLogLevel(s string) (testLogLevel, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return testLevelDebug, nil
+	case "info":
+		return testLevelInfo, nil
+	case "warn":
+		return testLevelWarn, nil
+	}
+
+	return 0, errors.New("unknown log level")
+}
+
+func TestGetAsCustomDecoder(t *testing.T) {
+	RegisterDecoder(parseTestLogLevel)
+
+	cfg := newTestConfig(t, "dev", "level = WARN\nbad_level = loud\n")
+
+	level, ok := GetAs[testLogLevel](cfg, "level")
+	assert.True(t, ok)
+	assert.Equal(t, testLevelWarn, level)
+
+	level, ok = GetAs(cfg, "missing_level", testLevelInfo)
+	assert.False(t, ok)
+	assert.Equal(t, testLevelInfo, level)
+
+	_, ok, err := TryGetAs[testLogLevel](cfg, "bad_level")
+	assert.True(t, ok)
+	assert.ErrorContains(t, err, "unknown log level")
+
+	_, ok = GetAs[testLogLevel](cfg, "bad_level")
+	assert.False(t, ok)
+
+	byKey := make(map[string]requestRecord)
+	for _, r := range cfg.requestedSnapshot() {
+		byKey[r.Key] = r
+	}
+
+	assert.Equal(t, "gocore.testLogLevel", byKey["level"].Type)
+	assert.Contains(t, byKey["level"].Callers[0], "config_decoders_test.go")
+	assert.Equal(t, "info", byKey["missing_level"].DefaultValue)
+}
+
+func TestGetAsBuiltinDecoders(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "timeout = 5s\nenabled = true\ncount = 7\n")
+
+	d, ok := GetAs[time.Duration](cfg, "timeout")
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, d)
+
+	b, ok := GetAs[bool](cfg, "enabled")
+	assert.True(t, ok)
+	assert.True(t, b)
+
+	n, ok := GetAs[int](cfg, "count")
+	assert.True(t, ok)
+	assert.Equal(t, 7, n)
+}
+
+func TestGetAsTypeLabels(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "timeout = 5s\nstarted = 2024-01-02T03:04:05Z\nip = 10.0.0.1\n")
+
+	_, _, _ = cfg.GetDuration("timeout")
+	_, _ = GetAs[time.Duration](cfg, "timeout")
+	_, _ = cfg.GetTime("started")
+	_, _ = GetAs[time.Time](cfg, "started")
+	_, _ = cfg.GetIP("ip")
+	_, _ = GetAs[net.IP](cfg, "ip")
+
+	assert.Empty(t, cfg.Conflicts())
+
+	for _, r := range cfg.requestedSnapshot() {
+		assert.NotContains(t, r.Type, ".", r.Key)
+	}
+}
+
+func TestGetAsNoDecoder(t *testing.T) {
+	type unregistered struct{}
+
+	cfg := newTestConfig(t, "dev", "")
+
+	_, _, err := TryGetAs[unregistered](cfg, "anything")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no decoder registered")
+}
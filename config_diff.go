@@ -0,0 +1,180 @@
+package gocore
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Causes of a difference between two configurations.
+const (
+	DiffCauseFile          = "file"
+	DiffCauseInterpolation = "interpolation"
+)
+
+// DiffEntry is a key whose effective value differs between two
+// configurations.  Secret values are masked.
+type DiffEntry struct {
+	Key string
+
+	Value  string
+	Found  bool
+	Source string // The key the value was found under, or a source label such as ENV
+	Origin string // file:line, if the value came from a settings file
+
+	OtherValue  string
+	OtherFound  bool
+	OtherSource string
+	OtherOrigin string
+
+	// Cause is DiffCauseFile if different values were declared for the two
+	// configurations, DiffCauseInterpolation if the same value was declared
+	// but its ${} variables resolve differently, or the source label, such as
+	// ENV, if the value was overridden from outside the settings files.
+	Cause string
+}
+
+// Diff compares the effective value of every key in this context with its
+// value in otherContext.
+func (c *Configuration) Diff(otherContext string) []DiffEntry {
+	c = c.root()
+
+	return c.DiffWith(c.withContext(otherContext))
+}
+
+// DiffWith compares the effective value of every key in c with its value in
+// other, which can be a different context or a configuration loaded from
+// different settings files.
+func (c *Configuration) DiffWith(other *Configuration) []DiffEntry {
+	c, other = c.root(), other.root()
+
+	keys := make(map[string]struct{})
+	for _, cfg := range []*Configuration{c, other} {
+		for _, k := range cfg.allKeys() {
+			keys[c.diffBaseKey(k, other)] = struct{}{}
+		}
+	}
+
+	var diffs []DiffEntry
+
+	for _, key := range sortedKeys(keys) {
+		value, found, source := c.resolve(key)
+		otherValue, otherFound, otherSource := other.resolve(key)
+
+		if value == otherValue && found == otherFound {
+			continue
+		}
+
+		raw, _, _ := c.resolveRaw(key)
+		otherRaw, _, _ := other.resolveRaw(key)
+
+		entry := DiffEntry{
+			Key:         key,
+			Value:       c.diffDisplayValue(key, value),
+			Found:       found,
+			Source:      source,
+			Origin:      c.originOf(source),
+			OtherValue:  other.diffDisplayValue(key, otherValue),
+			OtherFound:  otherFound,
+			OtherSource: otherSource,
+			OtherOrigin: other.originOf(otherSource),
+		}
+
+		switch {
+		case raw == otherRaw && found == otherFound:
+			entry.Cause = DiffCauseInterpolation
+		case found && !c.isFileSource(source):
+			entry.Cause = source
+		case otherFound && !other.isFileSource(otherSource):
+			entry.Cause = otherSource
+		default:
+			entry.Cause = DiffCauseFile
+		}
+
+		diffs = append(diffs, entry)
+	}
+
+	return diffs
+}
+
+// diffBaseKey strips any context or application suffix of either
+// configuration from k, so that url.live and url.stage are both compared as
+// url.
+func (c *Configuration) diffBaseKey(k string, other *Configuration) string {
+	longest := ""
+
+	for _, cfg := range []*Configuration{c, other} {
+		for _, suffix := range cfg.candidateKeys("") {
+			if suffix != "" && len(suffix) > len(longest) && strings.HasSuffix(k, suffix) && len(k) > len(suffix) {
+				longest = suffix
+			}
+		}
+	}
+
+	return strings.TrimSuffix(k, longest)
+}
+
+func (c *Configuration) isFileSource(source string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, found := c.confs[source]
+
+	return found
+}
+
+func (c *Configuration) originOf(source string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.origins[source]
+}
+
+// diffDisplayValue masks value if the key, or any variable it refers to, is a
+// secret.  The resolved value has already been decrypted, so this has to be
+// decided from the raw values.
+func (c *Configuration) diffDisplayValue(key, value string) string {
+	if c.isSecretKey(key, 0) {
+		return eheMask
+	}
+
+	return value
+}
+
+// FormatDiff renders diffs as a table, with name and otherName as the
+// headings for the two sides.
+func FormatDiff(diffs []DiffEntry, name, otherName string) string {
+	if len(diffs) == 0 {
+		return "No differences\n"
+	}
+
+	side := func(value string, found bool, source, origin string) string {
+		if !found {
+			return "(not set)"
+		}
+
+		where := source
+		if origin != "" {
+			where = shortCallers([]string{origin})
+		}
+
+		return fmt.Sprintf("%s [%s]", value, where)
+	}
+
+	var builder strings.Builder
+
+	w := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "KEY\t%s\t%s\tCAUSE\n", strings.ToUpper(name), strings.ToUpper(otherName))
+
+	for _, d := range diffs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			d.Key,
+			side(d.Value, d.Found, d.Source, d.Origin),
+			side(d.OtherValue, d.OtherFound, d.OtherSource, d.OtherOrigin),
+			d.Cause,
+		)
+	}
+	_ = w.Flush()
+
+	return builder.String()
+}
@@ -0,0 +1,97 @@
+package gocore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const diffSettings = `
+name          = Simon
+name.live     = Liam
+greeting      = Hello ${name}
+url           = http://localhost
+url.stage     = https://stage.example.com
+url.live      = https://example.com
+port          = 8080
+token         = *EHE*375dc2abb491dd879215a8b3d8d8fce52e6b6357c9c845e2e0a482e45eb69c43d711
+auth          = Bearer ${token}
+auth.live     = Basic ${token}
+only.live     = yes
+`
+
+func TestDiff(t *testing.T) {
+	cfg := newTestConfig(t, "stage", diffSettings)
+
+	diffs := cfg.Diff("live")
+
+	byKey := make(map[string]DiffEntry)
+	for _, d := range diffs {
+		byKey[d.Key] = d
+	}
+
+	require.Len(t, diffs, 5, FormatDiff(diffs, "stage", "live"))
+
+	url := byKey["url"]
+	assert.Equal(t, "https://stage.example.com", url.Value)
+	assert.Equal(t, "https://example.com", url.OtherValue)
+	assert.Equal(t, "url.stage", url.Source)
+	assert.Equal(t, "url.live", url.OtherSource)
+	assert.Equal(t, "settings.conf:6", url.Origin)
+	assert.Equal(t, DiffCauseFile, url.Cause)
+
+	greeting := byKey["greeting"]
+	assert.Equal(t, "Hello Simon", greeting.Value)
+	assert.Equal(t, "Hello Liam", greeting.OtherValue)
+	assert.Equal(t, DiffCauseInterpolation, greeting.Cause)
+
+	// auth is masked because it refers to a secret
+	auth := byKey["auth"]
+	assert.Equal(t, eheMask, auth.Value)
+	assert.Equal(t, eheMask, auth.OtherValue)
+	assert.Equal(t, DiffCauseFile, auth.Cause)
+
+	only := byKey["only"]
+	assert.False(t, only.Found)
+	assert.True(t, only.OtherFound)
+
+	_, found := byKey["port"]
+	assert.False(t, found)
+}
+
+func TestDiffWithLayer(t *testing.T) {
+	cfg := newTestConfig(t, "live", diffSettings)
+
+	other := newTestConfig(t, "live", diffSettings)
+	other.SetCommandLineSettings(map[string]string{"port": "9090"})
+
+	diffs := cfg.DiffWith(other)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, "port", diffs[0].Key)
+	assert.Equal(t, sourceCmdline, diffs[0].Cause)
+}
+
+func TestFormatDiff(t *testing.T) {
+	assert.Equal(t, "No differences\n", FormatDiff(nil, "a", "b"))
+
+	cfg := NewConfiguration("stage", "")
+	require.NoError(t, cfg.AddSettings("/etc/app/settings.conf", []byte(diffSettings)))
+
+	out := FormatDiff(cfg.Diff("live"), "stage", "live")
+	assert.Contains(t, out, "KEY")
+	assert.Contains(t, out, "STAGE")
+	assert.Contains(t, out, "https://example.com [settings.conf:7]")
+	assert.Contains(t, out, "(not set)")
+	assert.NotContains(t, out, "Bearer")
+}
+
+func TestSocketConfigDiff(t *testing.T) {
+	buf := &BufferWithClose{Buffer: &bytes.Buffer{}}
+	socketHandler := NewSocketHandler(Log("test"), buf)
+
+	socketHandler.handleConfig([]string{"config", "diff", "live"})
+	assert.Contains(t, buf.String(), "name")
+	assert.Contains(t, buf.String(), "CAUSE")
+}
@@ -0,0 +1,283 @@
+package gocore
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ordishs/gocore/parser"
+)
+
+// SettingDoc documents a root key, such as url, together with all of its
+// context variants, such as url.live and url.live.uk.
+type SettingDoc struct {
+	Key string
+	// Group is the gocore-format @group the key was declared in, if any.
+	Group string
+	// Comment is the comment block above the first declaration of the key.
+	Comment string
+	// Values holds every declared variant of the key.  Secrets are masked.
+	Values []DocValue
+	// Defaults and Types are the defaults and getter types the application
+	// has requested the key with so far.
+	Defaults []string
+	Types    []string
+}
+
+// DocValue is one declared variant of a setting.
+type DocValue struct {
+	Key    string
+	Value  string
+	Origin string
+}
+
+// Docs returns reference documentation for every declared or requested
+// setting, sorted by group and then key.  Comments and groups are read from
+// the settings files the values were loaded from, using the same conventions
+// as gocore-format.
+func (c *Configuration) Docs() []SettingDoc {
+	c = c.root()
+
+	docs := make(map[string]*SettingDoc)
+
+	get := func(rootKey string) *SettingDoc {
+		doc, found := docs[rootKey]
+		if !found {
+			doc = &SettingDoc{Key: rootKey}
+			docs[rootKey] = doc
+		}
+		return doc
+	}
+
+	c.mu.RLock()
+	files := make(map[string]struct{})
+	for k, v := range c.confs {
+		origin := c.origins[k]
+
+		doc := get(rootKeyOf(k))
+		doc.Values = append(doc.Values, DocValue{Key: k, Value: maskSecrets(v), Origin: origin})
+
+		if pos := strings.LastIndex(origin, ":"); pos != -1 {
+			files[origin[:pos]] = struct{}{}
+		}
+	}
+	c.mu.RUnlock()
+
+	comments := make(map[string]parser.Doc)
+	for _, file := range sortedKeys(files) {
+		for k, dc := range readDocComments(file) {
+			if _, found := comments[k]; !found {
+				comments[k] = dc
+			}
+		}
+	}
+
+	defaults := make(map[string]map[string]struct{})
+	types := make(map[string]map[string]struct{})
+	for _, r := range c.requestedSnapshot() {
+		rootKey := rootKeyOf(r.Key)
+		get(rootKey)
+
+		if defaults[rootKey] == nil {
+			defaults[rootKey] = make(map[string]struct{})
+			types[rootKey] = make(map[string]struct{})
+		}
+		if r.HasDefault {
+			defaults[rootKey][r.DefaultValue] = struct{}{}
+		}
+		types[rootKey][r.Type] = struct{}{}
+	}
+
+	result := make([]SettingDoc, 0, len(docs))
+	for rootKey, doc := range docs {
+		if dc, found := comments[rootKey]; found {
+			doc.Comment = strings.Join(dc.Comments, "\n")
+			doc.Group = dc.Group
+			if dc.Type != "" {
+				if types[rootKey] == nil {
+					types[rootKey] = make(map[string]struct{})
+				}
+				types[rootKey][dc.Type] = struct{}{}
+			}
+		}
+
+		doc.Defaults = sortedKeys(defaults[rootKey])
+		doc.Types = sortedKeys(types[rootKey])
+
+		sort.Slice(doc.Values, func(i, j int) bool {
+			return doc.Values[i].Key < doc.Values[j].Key
+		})
+
+		result = append(result, *doc)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Group != result[j].Group {
+			return result[i].Group < result[j].Group
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	return result
+}
+
+func rootKeyOf(key string) string {
+	return strings.Split(key, ".")[0]
+}
+
+// readDocComments returns the documentation of each root key in a settings
+// file, from the first declaration of the key.  A @type annotation on a later
+// declaration is used too.
+func readDocComments(filename string) map[string]parser.Doc {
+	comments := make(map[string]parser.Doc)
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return comments
+	}
+
+	// Syntax errors were already reported when the file was loaded
+	entries, _ := parser.Parse(filename, src)
+
+	for i, doc := range parser.Document(entries) {
+		if entries[i].Kind != parser.Setting {
+			continue
+		}
+
+		rootKey := rootKeyOf(entries[i].Key)
+		existing, found := comments[rootKey]
+		if !found {
+			comments[rootKey] = doc
+		} else if doc.Type != "" {
+			existing.Type = doc.Type
+			comments[rootKey] = existing
+		}
+	}
+
+	return comments
+}
+
+// WriteDocsMarkdown writes the settings reference as Markdown.
+func (c *Configuration) WriteDocsMarkdown(w io.Writer) error {
+	c = c.root()
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# Settings reference\n\nContext: `%s`", c.context)
+	if c.app != "" {
+		fmt.Fprintf(bw, ", application: `%s`", c.app)
+	}
+	bw.WriteString("\n")
+
+	group := "\x00"
+	for _, doc := range c.Docs() {
+		if doc.Group != group {
+			group = doc.Group
+			if group == "" {
+				bw.WriteString("\n## General\n")
+			} else {
+				fmt.Fprintf(bw, "\n## %s\n", group)
+			}
+		}
+
+		fmt.Fprintf(bw, "\n### %s\n\n", doc.Key)
+
+		if doc.Comment != "" {
+			bw.WriteString(doc.Comment + "\n\n")
+		}
+
+		if len(doc.Values) > 0 {
+			bw.WriteString("| Key | Value | Declared in |\n|-----|-------|-------------|\n")
+			for _, v := range doc.Values {
+				fmt.Fprintf(bw, "| `%s` | `%s` | %s |\n", v.Key, markdownCell(v.Value), markdownCell(shortCallers([]string{v.Origin})))
+			}
+		} else {
+			bw.WriteString("Not declared in any settings file.\n")
+		}
+
+		if len(doc.Types) > 0 {
+			fmt.Fprintf(bw, "\nType: %s\n", strings.Join(doc.Types, ", "))
+		}
+
+		if len(doc.Defaults) > 0 {
+			fmt.Fprintf(bw, "\nDefault: %s\n", strings.Join(quoteAll(doc.Defaults), ", "))
+		}
+	}
+
+	return bw.Flush()
+}
+
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func HandleConfigDocs(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "md" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		_ = Config().WriteDocsMarkdown(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	Config().printDocsHTML(w)
+}
+
+func (c *Configuration) printDocsHTML(p io.Writer) {
+	fmt.Fprintf(p, `<html>
+<head>
+<title>GoCore Settings Reference</title>
+<link rel='stylesheet' href='%scss/statistics.css' type='text/css' media='print, projection, screen' />
+</head>
+<body>
+<h1>GoCore Settings Reference</h1>
+<p>Context: %s %s &middot; <a href='%sconfig/docs?format=md'>Markdown</a> &middot; <a href='%sconfig'>Configuration</a></p>
+`, statPrefix, html.EscapeString(c.context), html.EscapeString(c.app), statPrefix, statPrefix)
+
+	group := "\x00"
+	for _, doc := range c.Docs() {
+		if doc.Group != group {
+			group = doc.Group
+			name := group
+			if name == "" {
+				name = "General"
+			}
+			fmt.Fprintf(p, "<h2>%s</h2>\r\n", html.EscapeString(name))
+		}
+
+		fmt.Fprintf(p, "<h3 id='%s'>%s</h3>\r\n", html.EscapeString(doc.Key), html.EscapeString(doc.Key))
+
+		if doc.Comment != "" {
+			fmt.Fprintf(p, "<p>%s</p>\r\n", strings.ReplaceAll(html.EscapeString(doc.Comment), "\n", "<br/>"))
+		}
+
+		if len(doc.Values) > 0 {
+			fmt.Fprintf(p, "<table class='tablesorter' border='0' cellpadding='0' cellspacing='1'>\r\n")
+			fmt.Fprintf(p, "<thead><tr><th>Key</th><th>Value</th><th>Declared in</th></tr></thead>\r\n<tbody>\r\n")
+			for _, v := range doc.Values {
+				fmt.Fprintf(p, "<tr><td>%s</td><td>%s</td><td>%s</td></tr>\r\n",
+					html.EscapeString(v.Key),
+					html.EscapeString(v.Value),
+					html.EscapeString(shortCallers([]string{v.Origin})),
+				)
+			}
+			fmt.Fprintf(p, "</tbody>\r\n</table>\r\n")
+		} else {
+			fmt.Fprintf(p, "<p>Not declared in any settings file.</p>\r\n")
+		}
+
+		if len(doc.Types) > 0 {
+			fmt.Fprintf(p, "<p>Type: %s</p>\r\n", html.EscapeString(strings.Join(doc.Types, ", ")))
+		}
+
+		if len(doc.Defaults) > 0 {
+			fmt.Fprintf(p, "<p>Default: %s</p>\r\n", html.EscapeString(strings.Join(quoteAll(doc.Defaults), ", ")))
+		}
+	}
+
+	fmt.Fprintf(p, "</body></html>\r\n")
+}
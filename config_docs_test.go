@@ -0,0 +1,113 @@
+package gocore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDocsConfig(t *testing.T) *Configuration {
+	content := `# The database host
+# used by every service
+database_host      = localhost
+database_host.live = db.example.com
+
+# @group: timeouts
+# How long to wait
+# @type duration
+read_timeout = 5s
+# write_timeout = 10s
+# @endgroup
+
+password = *EHE*abcdef
+`
+
+	filename := filepath.Join(t.TempDir(), "settings.conf")
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0600))
+
+	// The docs read the comments from the file itself
+	cfg := newTestConfig(t, "dev", "")
+	require.NoError(t, cfg.AddSettings(filename, []byte(content)))
+
+	return cfg
+}
+
+func TestDocs(t *testing.T) {
+	cfg := newDocsConfig(t)
+
+	_, _ = cfg.GetInt("undeclared_port", 8080)
+	_, _, _ = cfg.GetDuration("read_timeout")
+
+	docs := cfg.Docs()
+	require.Len(t, docs, 4)
+
+	byKey := make(map[string]SettingDoc)
+	for _, doc := range docs {
+		byKey[doc.Key] = doc
+	}
+
+	host := byKey["database_host"]
+	assert.Equal(t, "The database host\nused by every service", host.Comment)
+	assert.Equal(t, "", host.Group)
+	require.Len(t, host.Values, 2)
+	assert.Equal(t, "database_host.live", host.Values[1].Key)
+	assert.Equal(t, "db.example.com", host.Values[1].Value)
+	assert.True(t, strings.HasSuffix(host.Values[1].Origin, "settings.conf:4"))
+
+	timeout := byKey["read_timeout"]
+	assert.Equal(t, "How long to wait", timeout.Comment)
+	assert.Equal(t, "timeouts", timeout.Group)
+	assert.Equal(t, []string{"duration"}, timeout.Types)
+
+	assert.Equal(t, eheMask, byKey["password"].Values[0].Value)
+
+	port := byKey["undeclared_port"]
+	assert.Empty(t, port.Values)
+	assert.Equal(t, []string{"8080"}, port.Defaults)
+	assert.Equal(t, []string{"int"}, port.Types)
+
+	// Ungrouped settings come before grouped ones.
+	assert.Equal(t, "read_timeout", docs[3].Key)
+}
+
+func TestWriteDocsMarkdown(t *testing.T) {
+	cfg := newDocsConfig(t)
+
+	var builder strings.Builder
+	require.NoError(t, cfg.WriteDocsMarkdown(&builder))
+
+	md := builder.String()
+	assert.Contains(t, md, "# Settings reference\n\nContext: `dev`\n")
+	assert.Contains(t, md, "\n## General\n")
+	assert.Contains(t, md, "\n## timeouts\n")
+	assert.Contains(t, md, "### database_host\n\nThe database host\nused by every service\n")
+	assert.Contains(t, md, "| `database_host.live` | `db.example.com` | settings.conf:4 |\n")
+	assert.NotContains(t, md, "abcdef")
+}
+
+func TestHandleConfigDocs(t *testing.T) {
+	Config().Set("docs_xss_key", "<b>x</b>")
+
+	req := httptest.NewRequest(http.MethodGet, "/config/docs", nil)
+	rec := httptest.NewRecorder()
+	HandleConfigDocs(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "GoCore Settings Reference")
+	assert.Contains(t, body, "&lt;b&gt;x&lt;/b&gt;")
+	assert.NotContains(t, body, "<b>x</b>")
+
+	req = httptest.NewRequest(http.MethodGet, "/config/docs?format=md", nil)
+	rec = httptest.NewRecorder()
+	HandleConfigDocs(rec, req)
+
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/markdown")
+	assert.Contains(t, rec.Body.String(), "# Settings reference")
+}
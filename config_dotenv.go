@@ -0,0 +1,118 @@
+package gocore
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/joho/godotenv"
+)
+
+// sourceDotenv labels values read from the .env files.
+const sourceDotenv = "DOTENV"
+
+// dotenv holds the variables read from the .env files.  They are looked up
+// in the same way as environment variables, after the real environment, but
+// are never copied into it, so their provenance is always known.  A dotenv
+// is not changed once it has been loaded.
+type dotenv struct {
+	values map[string]string
+	files  []string // The files that were loaded, lowest precedence first
+}
+
+func (d *dotenv) lookup(name string) (string, bool) {
+	v, ok := d.values[name]
+	return v, ok
+}
+
+// dotenvFiles returns the .env files in dir that apply to context and app,
+// lowest precedence first.
+func dotenvFiles(dir, context, app string) []string {
+	var files []string
+
+	for _, name := range []string{".env", ".env." + context, ".env." + app, ".env.local"} {
+		file := filepath.Join(dir, name)
+		if name != ".env." && !slices.Contains(files, file) {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// readDotenv reads the files that exist, with later files overriding earlier
+// ones.  Files that cannot be parsed are skipped with a warning.
+func readDotenv(files []string) *dotenv {
+	d := &dotenv{values: make(map[string]string)}
+
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+
+		values, err := godotenv.Read(file)
+		if err != nil {
+			log.Printf("WARN: Failed to read env file '%s' - [%v]", file, err)
+			continue
+		}
+
+		for k, v := range values {
+			d.values[k] = v
+		}
+		d.files = append(d.files, file)
+	}
+
+	return d
+}
+
+// loadDotenv reads .env, .env.<context>, .env.<app> and .env.local from dir.
+// The context and application are taken from SETTINGS_CONTEXT and
+// SETTINGS_APPLICATION, which can themselves be set in .env or .env.local.
+// If SETTINGS_ENV_FILE is set, only that file is read.
+func loadDotenv(dir string) *dotenv {
+	if envFile := os.Getenv("SETTINGS_ENV_FILE"); envFile != "" {
+		return readDotenv([]string{envFile})
+	}
+
+	base := readDotenv([]string{filepath.Join(dir, ".env"), filepath.Join(dir, ".env.local")})
+
+	context := base.getenv("SETTINGS_CONTEXT")
+	if context == "" {
+		context = "dev"
+	}
+
+	return readDotenv(dotenvFiles(dir, context, base.getenv("SETTINGS_APPLICATION")))
+}
+
+// getenv returns the environment variable name, falling back to the .env
+// files.  It is used for the SETTINGS_* variables that control how the
+// configuration is loaded.
+func (d *dotenv) getenv(name string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+
+	if d != nil {
+		return d.values[name]
+	}
+
+	return ""
+}
+
+func (c *Configuration) getDotenv() *dotenv {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.dotenv
+}
+
+// dotenvSource returns the provenance label for a value read from the .env
+// variable name when key was requested.
+func dotenvSource(key, name string) string {
+	if name == key {
+		return sourceDotenv
+	}
+
+	return sourceDotenv + ":" + name
+}
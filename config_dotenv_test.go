@@ -0,0 +1,109 @@
+package gocore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDotenvFiles(t *testing.T) {
+	assert.Equal(t, []string{"d/.env", "d/.env.live", "d/.env.api", "d/.env.local"}, dotenvFiles("d", "live", "api"))
+	assert.Equal(t, []string{".env", ".env.dev", ".env.local"}, dotenvFiles("", "dev", ""))
+	assert.Equal(t, []string{".env", ".env.dev", ".env.local"}, dotenvFiles("", "dev", "dev"))
+}
+
+// unsetenv removes name from the environment for the duration of the test.
+func unsetenv(t *testing.T, name string) {
+	t.Setenv(name, "")
+	require.NoError(t, os.Unsetenv(name))
+}
+
+func TestLoadDotenv(t *testing.T) {
+	unsetenv(t, "SETTINGS_ENV_FILE")
+	unsetenv(t, "SETTINGS_CONTEXT")
+	unsetenv(t, "SETTINGS_APPLICATION")
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+
+	write(".env", "SETTINGS_CONTEXT=live\nDOTENV_A=base\nDOTENV_B=base\nDOTENV_C=base\n")
+	write(".env.live", "DOTENV_B=live\nDOTENV_C=live\n")
+	write(".env.stage", "DOTENV_B=stage\n")
+	write(".env.api", "DOTENV_C=api\n")
+	write(".env.local", "SETTINGS_APPLICATION=api\nDOTENV_A=local\n")
+
+	d := loadDotenv(dir)
+
+	assert.Equal(t, dotenvFiles(dir, "live", "api"), d.files)
+	assert.Equal(t, "live", d.getenv("SETTINGS_CONTEXT"))
+
+	for name, expected := range map[string]string{"DOTENV_A": "local", "DOTENV_B": "live", "DOTENV_C": "api"} {
+		v, ok := d.lookup(name)
+		assert.True(t, ok)
+		assert.Equal(t, expected, v, name)
+
+		// The process environment is left alone
+		_, ok = os.LookupEnv(name)
+		assert.False(t, ok)
+	}
+
+	t.Setenv("SETTINGS_CONTEXT", "stage")
+	d = loadDotenv(dir)
+
+	v, _ := d.lookup("DOTENV_B")
+	assert.Equal(t, "stage", v)
+
+	t.Setenv("SETTINGS_ENV_FILE", filepath.Join(dir, ".env.api"))
+	d = loadDotenv(dir)
+
+	assert.Equal(t, []string{filepath.Join(dir, ".env.api")}, d.files)
+	_, ok := d.lookup("DOTENV_A")
+	assert.False(t, ok)
+}
+
+func TestDotenvResolution(t *testing.T) {
+	cfg := newTestConfig(t, "live", "dotenv_host = localhost\ndotenv_port = 8000\n")
+
+	cfg.dotenv = &dotenv{
+		values: map[string]string{"dotenv_host": "from.env", "dotenv_port": "9000", "APP_DOTENV_USER__LIVE": "bob"},
+		files:  []string{".env", ".env.local"},
+	}
+
+	v, ok := cfg.Get("dotenv_host")
+	assert.True(t, ok)
+	assert.Equal(t, "from.env", v)
+
+	_, _, source := cfg.getInternal("dotenv_host")
+	assert.Equal(t, sourceDotenv, source)
+
+	// The real environment takes precedence
+	t.Setenv("dotenv_port", "9001")
+	port, _ := cfg.GetInt("dotenv_port")
+	assert.Equal(t, 9001, port)
+
+	_, _, source = cfg.getInternal("dotenv_port")
+	assert.Equal(t, "ENV", source)
+
+	assert.Equal(t, "from.env", cfg.GetAll()["dotenv_host"])
+
+	stats := cfg.Stats()
+	assert.Contains(t, stats, "Env files:   .env, .env.local\n")
+	assert.Contains(t, stats, "dotenv_host[DOTENV]=from.env\n")
+
+	cfg.SetEnvMapping(EnvMapping{Prefix: "APP_", DotSeparator: "__", UpperCase: true})
+
+	v, _ = cfg.Get("dotenv_user")
+	assert.Equal(t, "bob", v)
+
+	_, _, source = cfg.getInternal("dotenv_user")
+	assert.Equal(t, "DOTENV:APP_DOTENV_USER__LIVE", source)
+
+	// Copies share the .env files
+	v, _ = cfg.Clone().Get("dotenv_host")
+	assert.Equal(t, "from.env", v)
+}
@@ -0,0 +1,396 @@
+package gocore
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// originalValue is the value a key had in the settings files before it was
+// first changed at runtime.
+type originalValue struct {
+	value string
+	found bool
+}
+
+// rememberOriginal records the current value of key the first time it is
+// changed at runtime.  The caller must hold c.mu.
+func (c *Configuration) rememberOriginal(key string) {
+	if _, found := c.overridden[key]; found {
+		return
+	}
+
+	if c.overridden == nil {
+		c.overridden = make(map[string]originalValue)
+	}
+
+	value, found := c.confs[key]
+	c.overridden[key] = originalValue{value: value, found: found}
+}
+
+// Revert undoes any Set or Unset of key since the settings were loaded,
+// restoring its value from the settings files.  It returns the restored value
+// and false if the key has not been changed or a validator rejected the
+// restored value.
+func (c *Configuration) Revert(key string) (string, bool) {
+	value, found, err := c.TryRevert(key)
+	if err != nil {
+		log.Printf("WARN: Setting not reverted - [%v]", err)
+		return "", false
+	}
+
+	return value, found
+}
+
+// TryRevert is Revert, but returns the error from any validator that rejects
+// the restored value.
+func (c *Configuration) TryRevert(key string) (string, bool, error) {
+	c, key = c.root(), c.qualify(key)
+
+	_, value, found, err := c.revert(key)
+
+	return value, found, err
+}
+
+// revert is TryRevert for the root configuration.  It also returns the value
+// that was replaced, which is read under the same lock as the change.
+func (c *Configuration) revert(key string) (string, string, bool, error) {
+	c.changeMu.Lock()
+
+	c.mu.RLock()
+	original, found := c.overridden[key]
+	oldValue := c.confs[key]
+	c.mu.RUnlock()
+
+	if !found {
+		c.changeMu.Unlock()
+		return oldValue, "", false, nil
+	}
+
+	if err := c.validate(key, original.value); err != nil {
+		c.changeMu.Unlock()
+		return oldValue, "", true, err
+	}
+
+	c.mu.Lock()
+	delete(c.overridden, key)
+	c.generation.Add(1)
+
+	if original.found {
+		c.confs[key] = original.value
+	} else {
+		delete(c.confs, key)
+	}
+	c.mu.Unlock()
+
+	c.changeMu.Unlock()
+
+	c.notifyListeners(key, original.value)
+
+	return oldValue, original.value, true, nil
+}
+
+// overriddenKeys returns the keys that have been changed at runtime.
+func (c *Configuration) overriddenKeys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0, len(c.overridden))
+	for k := range c.overridden {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// ConfigChange is the body of a POST to {statPrefix}api/config.  Action is
+// set, unset or revert, and Value is only used by set.
+type ConfigChange struct {
+	Action string `json:"action"`
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+}
+
+// ConfigChangeResult is the response to a ConfigChange.  Encrypted values
+// are masked.
+type ConfigChangeResult struct {
+	Action   string `json:"action"`
+	Key      string `json:"key"`
+	OldValue string `json:"oldValue"`
+	Value    string `json:"value"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ApplyChange makes a change through Set, Unset or Revert, so that validators
+// and listeners are called exactly as they are for changes made over the
+// socket.
+func (c *Configuration) ApplyChange(change ConfigChange) (ConfigChangeResult, error) {
+	if change.Key == "" {
+		return ConfigChangeResult{Action: change.Action}, fmt.Errorf("key cannot be empty")
+	}
+
+	key := change.Key
+	change.Key = c.qualify(key)
+
+	result, err := c.root().applyChange(change)
+	result.Key = key
+
+	return result, err
+}
+
+func (c *Configuration) applyChange(change ConfigChange) (ConfigChangeResult, error) {
+	result := ConfigChangeResult{Action: change.Action, Key: change.Key}
+
+	// A key that is a secret before or after the change, such as one read
+	// from the secrets directory, is masked as it is on the settings page
+	secret := c.isSecretKey(change.Key, 0)
+
+	var err error
+
+	switch change.Action {
+	case "set":
+		result.OldValue, err = c.TrySet(change.Key, change.Value)
+		if err == nil {
+			result.Value = change.Value
+		}
+
+	case "unset":
+		result.OldValue, err = c.TryUnset(change.Key)
+
+	case "revert":
+		var found bool
+		result.OldValue, result.Value, found, err = c.revert(change.Key)
+		if err == nil && !found {
+			err = fmt.Errorf("%s has not been changed", change.Key)
+		}
+
+	default:
+		err = fmt.Errorf("unknown action %q, expected set, unset or revert", change.Action)
+	}
+
+	secret = secret || c.isSecretKey(change.Key, 0)
+
+	result.OldValue = maskChange(result.OldValue, secret)
+	result.Value = maskChange(result.Value, secret)
+
+	return result, err
+}
+
+// maskChange masks value, leaving it empty if it is empty so that unset
+// values still show as unset.
+func maskChange(value string, secret bool) string {
+	if secret && value != "" {
+		return eheMask
+	}
+
+	return maskSecrets(value)
+}
+
+// editCredentials returns the configured bearer token and basic auth
+// credentials.  They are read without being recorded, so that they never
+// appear in Requested().
+func (c *Configuration) editCredentials() (token, user, password string) {
+	get := func(key string) string {
+		v, _, _ := c.getInternal(key)
+		return strings.TrimPrefix(v, "*EHE*")
+	}
+
+	return get("configEditToken"), get("configEditUser"), get("configEditPassword")
+}
+
+// editEnabled reports whether configuration changes over HTTP are allowed,
+// which needs configEditToken or configEditUser and configEditPassword.
+func (c *Configuration) editEnabled() bool {
+	token, user, password := c.editCredentials()
+
+	return token != "" || (user != "" && password != "")
+}
+
+// authorizeEdit checks the request's bearer token or basic auth credentials
+// and returns the status code to reject it with, or 0 if it is allowed.
+func (c *Configuration) authorizeEdit(r *http.Request) int {
+	token, user, password := c.editCredentials()
+
+	if token == "" && (user == "" || password == "") {
+		return http.StatusForbidden
+	}
+
+	if token != "" {
+		auth := r.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") && secureEqual(strings.TrimPrefix(auth, "Bearer "), token) {
+			return 0
+		}
+	}
+
+	if user != "" && password != "" {
+		u, p, ok := r.BasicAuth()
+		if ok && secureEqual(u, user) && secureEqual(p, password) {
+			return 0
+		}
+	}
+
+	return http.StatusUnauthorized
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// isCrossSite reports whether a request came from another site.  Browsers
+// cannot send a JSON body to another origin without a CORS preflight, which
+// is never answered, and the Origin and Sec-Fetch-Site headers catch
+// anything else.
+func isCrossSite(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return true
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeChangeResult(w http.ResponseWriter, status int, result ConfigChangeResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// HandleConfigAPI changes a setting from a JSON ConfigChange.  It must be
+// enabled with configEditToken, which is sent as a bearer token, or with
+// configEditUser and configEditPassword for basic auth.
+func HandleConfigAPI(w http.ResponseWriter, r *http.Request) {
+	Config().handleConfigAPI(w, r)
+}
+
+func (c *Configuration) handleConfigAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeChangeResult(w, http.StatusMethodNotAllowed, ConfigChangeResult{Error: "only POST is allowed"})
+		return
+	}
+
+	if status := c.authorizeEdit(r); status != 0 {
+		msg := "configuration editing is disabled"
+		if status == http.StatusUnauthorized {
+			msg = "unauthorized"
+			if _, user, _ := c.editCredentials(); user != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="gocore config"`)
+			}
+		}
+		writeChangeResult(w, status, ConfigChangeResult{Error: msg})
+		return
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeChangeResult(w, http.StatusUnsupportedMediaType, ConfigChangeResult{Error: "expected an application/json body"})
+		return
+	}
+
+	if isCrossSite(r) {
+		writeChangeResult(w, http.StatusForbidden, ConfigChangeResult{Error: "cross-site requests are not allowed"})
+		return
+	}
+
+	var change ConfigChange
+
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&change); err != nil {
+		writeChangeResult(w, http.StatusBadRequest, ConfigChangeResult{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	result, err := c.ApplyChange(change)
+	if err != nil {
+		result.Error = err.Error()
+		writeChangeResult(w, http.StatusBadRequest, result)
+		return
+	}
+
+	writeChangeResult(w, http.StatusOK, result)
+}
+
+// printEditHTML writes the forms that change settings through the config API.
+func (c *Configuration) printEditHTML(p io.Writer) {
+	token, _, _ := c.editCredentials()
+
+	fmt.Fprintf(p, `<h2>Change a setting</h2>
+<form id='editForm' onsubmit='return false;'>
+<input type='text' id='editKey' placeholder='key' list='editKeys' />
+<input type='text' id='editValue' placeholder='value' size='60' />
+<button type='button' onclick='changeSetting("set")'>Set</button>
+<button type='button' onclick='changeSetting("unset")'>Unset</button>
+<button type='button' onclick='changeSetting("revert")'>Revert</button>
+</form>
+<datalist id='editKeys'>
+`)
+
+	for _, s := range c.settingsSnapshot() {
+		fmt.Fprintf(p, "<option value='%s'>\r\n", html.EscapeString(s.Key))
+	}
+
+	fmt.Fprintf(p, `</datalist>
+<script type='text/javascript'>
+var useToken = %t;
+
+function changeSetting(action, key) {
+	var change = { action: action, key: key || document.getElementById('editKey').value };
+	if (action == 'set') {
+		change.value = document.getElementById('editValue').value;
+	}
+
+	var headers = { 'Content-Type': 'application/json' };
+	if (useToken) {
+		var token = sessionStorage.getItem('gocoreConfigToken') || prompt('Token');
+		if (!token) {
+			return;
+		}
+		sessionStorage.setItem('gocoreConfigToken', token);
+		headers['Authorization'] = 'Bearer ' + token;
+	}
+
+	fetch('%sapi/config', { method: 'POST', credentials: 'same-origin', headers: headers, body: JSON.stringify(change) })
+		.then(function(resp) {
+			if (resp.status == 401 && useToken) {
+				sessionStorage.removeItem('gocoreConfigToken');
+			}
+			return resp.json();
+		})
+		.then(function(result) {
+			if (result.error) {
+				alert(result.error);
+			} else {
+				location.reload();
+			}
+		});
+}
+</script>
+`, token != "", statPrefix)
+
+	keys := c.overriddenKeys()
+	if len(keys) == 0 {
+		return
+	}
+
+	fmt.Fprintf(p, "<h2>Runtime changes</h2>\r\n<ul id='overridden'>\r\n")
+	for _, k := range keys {
+		key := html.EscapeString(k)
+		fmt.Fprintf(p, "<li>%s <button type='button' data-key='%s' onclick='changeSetting(\"revert\", this.dataset.key)'>Revert</button></li>\r\n", key, key)
+	}
+	fmt.Fprintf(p, "</ul>\r\n")
+}
@@ -0,0 +1,161 @@
+package gocore
+
+import (
+	"os"
+	"strings"
+)
+
+// EnvMapping controls how setting keys are mapped to environment variable
+// names.  The zero value keeps the original behaviour, where only an
+// environment variable with exactly the same name as the key is used.
+//
+// With Prefix "APP_", DotSeparator "__" and UpperCase set, the key url.live
+// is read from APP_URL__LIVE.  Mapped names take part in the usual context
+// and application fallback, so APP_URL__LIVE overrides url when the context
+// is live.
+type EnvMapping struct {
+	Prefix         string
+	DotSeparator   string
+	UpperCase      bool
+	DisableBareKey bool
+}
+
+func (m EnvMapping) enabled() bool {
+	return m.Prefix != "" || m.DotSeparator != "" || m.UpperCase
+}
+
+// envName returns the mapped environment variable name for key.
+func (m EnvMapping) envName(key string) string {
+	name := key
+	if m.DotSeparator != "" {
+		name = strings.ReplaceAll(name, ".", m.DotSeparator)
+	}
+	if m.UpperCase {
+		name = strings.ToUpper(name)
+	}
+
+	return m.Prefix + name
+}
+
+// EnvName returns the environment variable that overrides key, which is the
+// key itself unless a mapping is configured.
+func (m EnvMapping) EnvName(key string) string {
+	if !m.enabled() {
+		return key
+	}
+
+	return m.envName(key)
+}
+
+// SetEnvMapping sets the rules used to map keys to environment variables.
+func (c *Configuration) SetEnvMapping(m EnvMapping) {
+	c = c.root()
+
+	c.changeMu.Lock()
+	defer c.changeMu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.envMapping = m
+	c.generation.Add(1)
+}
+
+func (c *Configuration) getEnvMapping() EnvMapping {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.envMapping
+}
+
+// lookupEnvKeys resolves key from the environment, and then from the .env
+// files, trying the mapped names of candidates, most specific first, and then
+// the bare names.  It returns the value, its provenance label and the key that
+// supplied it.
+func (c *Configuration) lookupEnvKeys(key string, candidates, bare []string) (string, string, string, bool) {
+	m := c.getEnvMapping()
+
+	if val, name, keyUsed, ok := m.lookup(candidates, bare, os.LookupEnv); ok {
+		return val, envSource(key, name), keyUsed, true
+	}
+
+	if d := c.getDotenv(); d != nil {
+		if val, name, keyUsed, ok := m.lookup(candidates, bare, d.lookup); ok {
+			return val, dotenvSource(key, name), keyUsed, true
+		}
+	}
+
+	return "", "", "", false
+}
+
+// lookup tries the mapped names of candidates and then the bare names with
+// get, and returns the value, the name that supplied it and the key that the
+// name stands for.
+func (m EnvMapping) lookup(candidates, bare []string, get func(string) (string, bool)) (string, string, string, bool) {
+	if m.enabled() {
+		for _, k := range candidates {
+			name := m.envName(k)
+			if val, ok := get(name); ok {
+				return val, name, k, true
+			}
+		}
+	}
+
+	if !m.DisableBareKey {
+		for _, k := range bare {
+			if val, ok := get(k); ok {
+				return val, k, k, true
+			}
+		}
+	}
+
+	return "", "", "", false
+}
+
+// envNames returns the declared keys whose values come from a mapped
+// environment variable, or one in a .env file, with the name of that
+// variable.
+func (c *Configuration) envNames() map[string]string {
+	mapping := c.getEnvMapping()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	m := make(map[string]string)
+	for k := range c.confs {
+		if _, name, ok := mapping.lookupExact(k, c.dotenv); ok && name != k {
+			m[k] = name
+		}
+	}
+
+	return m
+}
+
+// lookupExact is like Configuration.lookupEnvKeys but without any context
+// fallback, so that it can be used for fully qualified keys such as url.live.
+// The .env files in d, which may be nil, are consulted after the environment.
+func (m EnvMapping) lookupExact(key string, d *dotenv) (string, string, bool) {
+	keys := []string{key}
+
+	if val, name, _, ok := m.lookup(keys, keys, os.LookupEnv); ok {
+		return val, name, true
+	}
+
+	if d != nil {
+		if val, name, _, ok := m.lookup(keys, keys, d.lookup); ok {
+			return val, name, true
+		}
+	}
+
+	return "", "", false
+}
+
+// envSource returns the provenance label for a value read from the
+// environment variable name when key was requested.
+func envSource(key, name string) string {
+	if name == key {
+		return "ENV"
+	}
+
+	return "ENV:" + name
+}
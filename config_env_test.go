@@ -0,0 +1,52 @@
+package gocore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvMappingName(t *testing.T) {
+	m := EnvMapping{Prefix: "APP_", DotSeparator: "__", UpperCase: true}
+	assert.Equal(t, "APP_URL__LIVE", m.envName("url.live"))
+	assert.Equal(t, "APP_LOGGER_SHOW_TIMESTAMPS", m.envName("logger_show_timestamps"))
+
+	assert.False(t, EnvMapping{}.enabled())
+	assert.Equal(t, "url.live", EnvMapping{}.envName("url.live"))
+}
+
+func TestEnvMappingLookup(t *testing.T) {
+	cfg := newTestConfig(t, "live", "url = http://localhost\nurl.live = https://live\nmap_city = Paris\n")
+	cfg.SetEnvMapping(EnvMapping{Prefix: "APP_", DotSeparator: "__", UpperCase: true})
+
+	t.Setenv("APP_URL__LIVE", "https://env-live")
+	t.Setenv("map_city", "Rome")
+
+	v, ok := cfg.Get("url")
+	assert.True(t, ok)
+	assert.Equal(t, "https://env-live", v)
+
+	// Bare keys are still honoured by default
+	v, _ = cfg.Get("map_city")
+	assert.Equal(t, "Rome", v)
+
+	sources := make(map[string]string)
+	for _, r := range cfg.requestedSnapshot() {
+		sources[r.Key] = r.Source
+	}
+	assert.Equal(t, "ENV:APP_URL__LIVE", sources["url"])
+	assert.Equal(t, "ENV", sources["map_city"])
+
+	all := cfg.GetAll()
+	assert.Equal(t, "https://env-live", all["url.live"])
+	assert.Equal(t, "http://localhost", all["url"])
+	assert.Equal(t, "APP_URL__LIVE", all["_ENV:url.live"])
+	assert.NotContains(t, all, "_ENV:url")
+
+	assert.Contains(t, cfg.Stats(), "url[ENV:APP_URL__LIVE]=https://env-live\n")
+
+	cfg.SetEnvMapping(EnvMapping{Prefix: "APP_", DotSeparator: "__", UpperCase: true, DisableBareKey: true})
+
+	v, _ = cfg.Get("map_city")
+	assert.Equal(t, "Paris", v)
+}
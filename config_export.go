@@ -0,0 +1,78 @@
+package gocore
+
+import (
+	"strings"
+
+	"github.com/ordishs/gocore/utils"
+)
+
+// ExportedSetting is the effective value of a key, as returned by Export.
+type ExportedSetting struct {
+	Key    string
+	Value  string
+	Secret bool
+}
+
+// Export resolves every declared key for this context and application,
+// including ${} interpolation, so that the result can be written out as an
+// env file or manifest.  Keys are exported without their context or
+// application suffix, and variants for other contexts are left out.
+//
+// Unless decryptSecrets is set, encrypted values are exported in their
+// *EHE* form and values that refer to a secret keep their ${} references, so
+// that nothing is exported in plain text.  Values from the secrets directory
+// are only exported when decryptSecrets is set.
+func (c *Configuration) Export(decryptSecrets bool) []ExportedSetting {
+	c = c.root()
+
+	var exported []ExportedSetting
+
+	for _, key := range c.exportKeys() {
+		raw, ok, source := c.resolveRaw(key)
+		if !ok {
+			continue
+		}
+
+		secret := c.isSecretKey(key, 0)
+
+		value := decryptAll(c.replaceVariables(raw))
+		if secret && !decryptSecrets {
+			if isSecretSource(source) {
+				continue
+			}
+			value = raw
+		}
+
+		exported = append(exported, ExportedSetting{Key: key, Value: value, Secret: secret})
+	}
+
+	return exported
+}
+
+// decryptAll decrypts every encrypted part of value, as GetURL does for
+// passwords, and removes the *EHE* markers.
+func decryptAll(value string) string {
+	return reEHE.ReplaceAllStringFunc(value, func(ehe string) string {
+		decrypted, err := utils.DecryptSetting(ehe)
+		if err != nil {
+			return ehe
+		}
+
+		return strings.TrimPrefix(decrypted, "*EHE*")
+	})
+}
+
+// exportKeys returns the declared keys with their context and application
+// suffixes removed, so that url, url.live and url.stage are all exported as
+// url with the value that applies here.  Keys that only have variants for
+// other contexts do not resolve, and are left out by Export.
+func (c *Configuration) exportKeys() []string {
+	contexts := c.contextNames()
+
+	var keys []string
+	for _, k := range c.allKeys() {
+		keys = append(keys, baseKey(k, contexts))
+	}
+
+	return uniqueSorted(keys)
+}
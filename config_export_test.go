@@ -0,0 +1,87 @@
+package gocore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exportSettings = `
+url              = http://localhost
+url.live         = https://example.com
+url.live.app     = https://app.example.com
+url.stage        = https://stage.example.com
+name             = Simon
+name.live.eupriv = Zaynah
+greeting         = Hello ${name}
+greeting.stage   = Hi ${name}
+peers.alice      = http://a
+peers.alice.live = https://a
+token            = *EHE*375dc2abb491dd879215a8b3d8d8fce52e6b6357c9c845e2e0a482e45eb69c43d711
+auth             = Bearer ${token}
+`
+
+func exportedByKey(settings []ExportedSetting) map[string]ExportedSetting {
+	m := make(map[string]ExportedSetting)
+	for _, s := range settings {
+		m[s.Key] = s
+	}
+
+	return m
+}
+
+func TestExport(t *testing.T) {
+	cfg := newTestConfig(t, "live", exportSettings)
+	cfg.app = "app"
+
+	exported := cfg.Export(false)
+
+	keys := make([]string, len(exported))
+	for i, s := range exported {
+		keys[i] = s.Key
+	}
+	assert.Equal(t, []string{"auth", "greeting", "name", "peers.alice", "token", "url"}, keys)
+
+	m := exportedByKey(exported)
+	assert.Equal(t, "https://app.example.com", m["url"].Value)
+	assert.Equal(t, "Hello Simon", m["greeting"].Value)
+	assert.Equal(t, "https://a", m["peers.alice"].Value)
+	assert.False(t, m["url"].Secret)
+
+	// Secrets stay encrypted and references to them are left for gocore to
+	// resolve at runtime.
+	assert.True(t, m["token"].Secret)
+	assert.Equal(t, "*EHE*375dc2abb491dd879215a8b3d8d8fce52e6b6357c9c845e2e0a482e45eb69c43d711", m["token"].Value)
+	assert.True(t, m["auth"].Secret)
+	assert.Equal(t, "Bearer ${token}", m["auth"].Value)
+}
+
+func TestExportContextKeys(t *testing.T) {
+	cfg := newTestConfig(t, "dev", `
+host          = localhost
+host.live     = example.com
+port.live     = 443
+kafka         = localhost:9092
+kafka.brokers = 3
+`)
+
+	m := exportedByKey(cfg.Export(false))
+
+	// port only has a value in live, and kafka.brokers is a key of its own
+	assert.Equal(t, map[string]ExportedSetting{
+		"host":          {Key: "host", Value: "localhost"},
+		"kafka":         {Key: "kafka", Value: "localhost:9092"},
+		"kafka.brokers": {Key: "kafka.brokers", Value: "3"},
+	}, m)
+}
+
+func TestExportDecryptSecrets(t *testing.T) {
+	cfg := newTestConfig(t, "live", exportSettings)
+
+	m := exportedByKey(cfg.Export(true))
+
+	require.True(t, m["token"].Secret)
+	assert.NotContains(t, m["token"].Value, "*EHE*")
+	assert.Equal(t, "Bearer "+m["token"].Value, m["auth"].Value)
+}
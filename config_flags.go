@@ -0,0 +1,340 @@
+package gocore
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+)
+
+// flagPrefix is prepended to a flag's name to find its setting, so the
+// newui flag is configured with flag.newui (or flag.newui.live, etc.).
+const flagPrefix = "flag."
+
+// flagBuckets is the number of buckets subjects are hashed into, which lets
+// rollouts be given to a hundredth of a percent.
+const flagBuckets = 10000
+
+// FeatureFlag is a feature that is switched on for all, some or none of its
+// subjects by the flag.<name> setting.  The setting can be:
+//
+//	true, false          on or off for everyone
+//	25%                  on for a stable 25% of subjects
+//	allow:alice,bob      on for the listed subjects only
+//	allow:alice;10%      on for alice and for 10% of everyone else
+//
+// The setting is resolved for the context and application of the
+// configuration the flag was created from, and changes to it take effect on
+// the next evaluation.
+type FeatureFlag struct {
+	name string
+	key  string
+	cfg  *Configuration
+
+	mu   sync.Mutex
+	spec string
+	rule flagRule
+
+	enabled  atomic.Int64
+	disabled atomic.Int64
+}
+
+// flagRule is a parsed flag setting.
+type flagRule struct {
+	all     bool
+	allowed map[string]struct{}
+	buckets int // subjects that hash below this are enabled
+	err     error
+}
+
+// FlagStats reports how often a flag has been evaluated.
+type FlagStats struct {
+	Name     string
+	Spec     string
+	Enabled  int64
+	Disabled int64
+}
+
+type flagOverridesKey struct{}
+
+// flagSet holds the flags that have been created from a configuration, by
+// setting key.
+type flagSet struct {
+	mu    sync.Mutex
+	byKey map[string]*FeatureFlag
+}
+
+// Flag returns the feature flag with the given name in the default
+// configuration, creating it the first time it is asked for.
+func Flag(name string) *FeatureFlag {
+	return Config().Flag(name)
+}
+
+// Flag returns the feature flag with the given name, creating it the first
+// time it is asked for.  The flag reads its setting from this configuration,
+// so a flag from a view returned by Sub reads <prefix>flag.<name>.
+func (c *Configuration) Flag(name string) *FeatureFlag {
+	c, key := c.root(), c.qualify(flagPrefix+name)
+
+	c.mu.Lock()
+	if c.flags == nil {
+		c.flags = &flagSet{byKey: make(map[string]*FeatureFlag)}
+	}
+	set := c.flags
+	c.mu.Unlock()
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	f, found := set.byKey[key]
+	if !found {
+		f = &FeatureFlag{name: name, key: key, cfg: c}
+		set.byKey[key] = f
+	}
+
+	return f
+}
+
+func newFeatureFlag(c *Configuration, name string) *FeatureFlag {
+	return &FeatureFlag{name: name, key: flagPrefix + name, cfg: c}
+}
+
+// WithFlag returns a copy of ctx in which the named flag is forced on or off,
+// whatever its setting says.  This is useful in tests, or to let a request
+// opt in to a feature.
+func WithFlag(ctx context.Context, name string, enabled bool) context.Context {
+	overrides := make(map[string]bool)
+	if existing, ok := ctx.Value(flagOverridesKey{}).(map[string]bool); ok {
+		for k, v := range existing {
+			overrides[k] = v
+		}
+	}
+	overrides[name] = enabled
+
+	return context.WithValue(ctx, flagOverridesKey{}, overrides)
+}
+
+// Name returns the name of the flag.
+func (f *FeatureFlag) Name() string {
+	return f.name
+}
+
+// Enabled reports whether the flag is on for subjectID, such as a user or
+// account ID.  A subject always gets the same answer for the same setting,
+// and raising a percentage only ever adds subjects.
+func (f *FeatureFlag) Enabled(ctx context.Context, subjectID string) bool {
+	enabled := f.evaluate(ctx, subjectID)
+
+	if enabled {
+		f.enabled.Add(1)
+	} else {
+		f.disabled.Add(1)
+	}
+
+	return enabled
+}
+
+func (f *FeatureFlag) evaluate(ctx context.Context, subjectID string) bool {
+	if ctx != nil {
+		if overrides, ok := ctx.Value(flagOverridesKey{}).(map[string]bool); ok {
+			if enabled, found := overrides[f.name]; found {
+				return enabled
+			}
+		}
+	}
+
+	rule := f.currentRule()
+
+	if rule.all {
+		return true
+	}
+
+	if _, found := rule.allowed[subjectID]; found {
+		return true
+	}
+
+	return rule.buckets > 0 && flagBucket(f.name, subjectID) < rule.buckets
+}
+
+// currentRule returns the parsed setting, parsing it again only when it has
+// changed.  The setting is read without being recorded, as flags are
+// evaluated far more often than settings are requested.
+func (f *FeatureFlag) currentRule() flagRule {
+	spec, _, _ := f.cfg.getInternal(f.key)
+	spec = strings.TrimPrefix(spec, "*EHE*")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// The zero rule is what an empty setting parses to
+	if spec != f.spec {
+		f.spec = spec
+		f.rule = parseFlagRule(spec)
+
+		if f.rule.err != nil {
+			log.Printf("WARN: Flag %q is disabled, invalid setting %q - [%v]", f.name, spec, f.rule.err)
+		}
+	}
+
+	return f.rule
+}
+
+// parseFlagRule parses a flag setting.  Terms are separated by semicolons and
+// an invalid setting disables the flag.
+func parseFlagRule(spec string) flagRule {
+	var rule flagRule
+
+	for _, term := range strings.Split(spec, ";") {
+		term = strings.TrimSpace(term)
+
+		switch {
+		case term == "":
+
+		case strings.HasPrefix(term, "allow:"):
+			if rule.allowed == nil {
+				rule.allowed = make(map[string]struct{})
+			}
+			for _, subject := range strings.Split(strings.TrimPrefix(term, "allow:"), ",") {
+				if subject = strings.TrimSpace(subject); subject != "" {
+					rule.allowed[subject] = struct{}{}
+				}
+			}
+
+		case strings.HasSuffix(term, "%"):
+			pct, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(term, "%")), 64)
+			if err != nil || pct < 0 || pct > 100 {
+				return flagRule{err: fmt.Errorf("%q is not a percentage between 0%% and 100%%", term)}
+			}
+			rule.buckets = int(pct * flagBuckets / 100)
+
+		default:
+			on, err := strconv.ParseBool(term)
+			if err != nil {
+				return flagRule{err: fmt.Errorf("expected true, false, a percentage or allow:<subjects>, got %q", term)}
+			}
+			rule.all = on
+		}
+	}
+
+	return rule
+}
+
+// flagBucket hashes the flag name and subject to a bucket.  The name is
+// included so that each flag is rolled out to a different set of subjects.
+func flagBucket(name, subjectID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(subjectID))
+
+	return int(h.Sum32() % flagBuckets)
+}
+
+// Stats returns how often the flag has been evaluated.
+func (f *FeatureFlag) Stats() FlagStats {
+	spec, _, source := f.cfg.getInternal(f.key)
+
+	return FlagStats{
+		Name:     f.name,
+		Spec:     maskValue(spec, source),
+		Enabled:  f.enabled.Load(),
+		Disabled: f.disabled.Load(),
+	}
+}
+
+// Flags returns the evaluation counts of every flag that has been used in
+// the default configuration, sorted by name.
+func Flags() []FlagStats {
+	return Config().Flags()
+}
+
+// Flags returns the evaluation counts of every flag that has been created
+// from this configuration, sorted by name.
+func (c *Configuration) Flags() []FlagStats {
+	c = c.root()
+
+	c.mu.RLock()
+	set := c.flags
+	c.mu.RUnlock()
+
+	if set == nil {
+		return []FlagStats{}
+	}
+
+	set.mu.Lock()
+	all := make([]*FeatureFlag, 0, len(set.byKey))
+	for _, f := range set.byKey {
+		all = append(all, f)
+	}
+	set.mu.Unlock()
+
+	stats := make([]FlagStats, 0, len(all))
+	for _, f := range all {
+		stats = append(stats, f.Stats())
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+
+	return stats
+}
+
+// FormatFlags renders flag statistics as a table.
+func FormatFlags(stats []FlagStats) string {
+	if len(stats) == 0 {
+		return "No flags have been evaluated\n"
+	}
+
+	var builder strings.Builder
+	w := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "FLAG\tSETTING\tENABLED\tDISABLED")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", s.Name, s.Spec, s.Enabled, s.Disabled)
+	}
+
+	_ = w.Flush()
+
+	return builder.String()
+}
+
+// HandleFlags shows the feature flags and how often they have been evaluated.
+func HandleFlags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	printFlagsHTML(w, Flags())
+}
+
+func printFlagsHTML(p io.Writer, stats []FlagStats) {
+	fmt.Fprintf(p, `<html>
+<head>
+<title>GoCore Feature Flags</title>
+<link rel='stylesheet' href='%scss/statistics.css' type='text/css' media='print, projection, screen' />
+</head>
+<body>
+<h1>GoCore Feature Flags</h1>
+<table id='flagsTable' class='tablesorter' border='0' cellpadding='0' cellspacing='1'>
+<thead><tr><th>Flag</th><th>Setting</th><th>Enabled</th><th>Disabled</th></tr></thead>
+<tbody>
+`, statPrefix)
+
+	for _, s := range stats {
+		fmt.Fprintf(p, "<tr><td>%s</td><td>%s</td><td align='right'>%d</td><td align='right'>%d</td></tr>\r\n",
+			html.EscapeString(s.Name),
+			html.EscapeString(s.Spec),
+			s.Enabled,
+			s.Disabled,
+		)
+	}
+
+	fmt.Fprintf(p, "</tbody>\r\n</table>\r\n</body></html>\r\n")
+}
@@ -0,0 +1,160 @@
+package gocore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlagRules(t *testing.T) {
+	cfg := newTestConfig(t, "live", `
+flag.on = true
+flag.off = false
+flag.beta = allow:alice, bob
+flag.mixed = allow:alice;50%
+flag.broken = sometimes
+flag.ctx = false
+flag.ctx.live = true
+`)
+
+	ctx := context.Background()
+
+	assert.True(t, newFeatureFlag(cfg, "on").Enabled(ctx, "anyone"))
+	assert.False(t, newFeatureFlag(cfg, "off").Enabled(ctx, "anyone"))
+	assert.False(t, newFeatureFlag(cfg, "missing").Enabled(ctx, "anyone"))
+	assert.False(t, newFeatureFlag(cfg, "broken").Enabled(ctx, "anyone"))
+	assert.True(t, newFeatureFlag(cfg, "ctx").Enabled(ctx, "anyone"))
+
+	beta := newFeatureFlag(cfg, "beta")
+	assert.True(t, beta.Enabled(ctx, "alice"))
+	assert.True(t, beta.Enabled(ctx, "bob"))
+	assert.False(t, beta.Enabled(ctx, "carol"))
+
+	mixed := newFeatureFlag(cfg, "mixed")
+	assert.True(t, mixed.Enabled(ctx, "alice"))
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		if mixed.Enabled(ctx, fmt.Sprintf("user-%d", i)) {
+			enabled++
+		}
+	}
+	assert.InDelta(t, 500, enabled, 60)
+
+	stats := mixed.Stats()
+	assert.Equal(t, "mixed", stats.Name)
+	assert.Equal(t, "allow:alice;50%", stats.Spec)
+	assert.Equal(t, int64(enabled+1), stats.Enabled)
+	assert.Equal(t, int64(1000-enabled), stats.Disabled)
+
+	// Flags are never recorded as requested settings
+	assert.Empty(t, cfg.requestedSnapshot())
+}
+
+func TestFlagRollout(t *testing.T) {
+	cfg := NewConfiguration("dev", "")
+	cfg.Set("flag.newui", "10%")
+
+	f := newFeatureFlag(cfg, "newui")
+	ctx := context.Background()
+
+	var before []string
+	for i := 0; i < 1000; i++ {
+		subject := fmt.Sprintf("user-%d", i)
+		if f.Enabled(ctx, subject) {
+			before = append(before, subject)
+		}
+	}
+
+	// The same subjects get the same answer, and raising the percentage only
+	// adds subjects
+	cfg.Set("flag.newui", "30%")
+	for _, subject := range before {
+		assert.True(t, f.Enabled(ctx, subject), subject)
+	}
+
+	cfg.Set("flag.newui", "0%")
+	assert.False(t, f.Enabled(ctx, before[0]))
+
+	cfg.Set("flag.newui", "100%")
+	assert.True(t, f.Enabled(ctx, "anyone"))
+}
+
+func TestFlagContextOverride(t *testing.T) {
+	cfg := NewConfiguration("dev", "")
+	cfg.Set("flag.newui", "false")
+
+	f := newFeatureFlag(cfg, "newui")
+
+	ctx := WithFlag(context.Background(), "newui", true)
+	assert.True(t, f.Enabled(ctx, "alice"))
+	assert.False(t, f.Enabled(WithFlag(ctx, "newui", false), "alice"))
+	assert.False(t, f.Enabled(context.Background(), "alice"))
+}
+
+func TestParseFlagRuleErrors(t *testing.T) {
+	assert.EqualError(t, parseFlagRule("150%").err, `"150%" is not a percentage between 0% and 100%`)
+	assert.EqualError(t, parseFlagRule("maybe").err, `expected true, false, a percentage or allow:<subjects>, got "maybe"`)
+	assert.NoError(t, parseFlagRule("").err)
+	assert.Equal(t, 1250, parseFlagRule("12.5%").buckets)
+}
+
+func TestFlagsPage(t *testing.T) {
+	stats := []FlagStats{{Name: "<newui>", Spec: "25%", Enabled: 3, Disabled: 9}}
+
+	var buf bytes.Buffer
+	printFlagsHTML(&buf, stats)
+	assert.Contains(t, buf.String(), "<tr><td>&lt;newui&gt;</td><td>25%</td><td align='right'>3</td><td align='right'>9</td></tr>")
+
+	assert.Contains(t, FormatFlags(stats), "<newui>  25%      3        9\n")
+	assert.Equal(t, "No flags have been evaluated\n", FormatFlags(nil))
+}
+
+func TestFlagRegistry(t *testing.T) {
+	f := Flag("registry_test")
+	assert.Same(t, f, Flag("registry_test"))
+
+	f.Enabled(context.Background(), "alice")
+
+	var found bool
+	for _, s := range Flags() {
+		if s.Name == "registry_test" {
+			found = true
+			assert.Equal(t, int64(1), s.Disabled)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestConfigurationFlag(t *testing.T) {
+	cfg := newTestConfig(t, "live", "flag.newui = true\nflag.newui.live = false\ndb_flag.pool = true\n")
+	ctx := context.Background()
+
+	f := cfg.Flag("cfg_newui_test")
+	assert.Same(t, f, cfg.Flag("cfg_newui_test"))
+	assert.NotSame(t, f, Flag("cfg_newui_test"))
+
+	newui := cfg.Flag("newui")
+	assert.False(t, newui.Enabled(ctx, "alice"))
+
+	cfg.Set("flag.newui.live", "true")
+	assert.True(t, newui.Enabled(ctx, "alice"))
+
+	// A view returned by Sub reads the flag under its prefix
+	assert.True(t, cfg.Sub("db_").Flag("pool").Enabled(ctx, "alice"))
+
+	// Overrides on a clone only change the clone's flags
+	clone := cfg.Clone()
+	clone.SetOverrides(map[string]string{"flag.newui": "false"})
+	assert.False(t, clone.Flag("newui").Enabled(ctx, "alice"))
+	assert.True(t, newui.Enabled(ctx, "alice"))
+
+	names := make([]string, 0)
+	for _, s := range cfg.Flags() {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"cfg_newui_test", "newui", "pool"}, names)
+}
@@ -0,0 +1,178 @@
+package gocore
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConfigReport is the machine readable form of the /config page, served as
+// JSON at {statPrefix}config.json.  Secrets are masked exactly as they are on
+// the page.
+type ConfigReport struct {
+	Context    string `json:"context"`
+	App        string `json:"app"`
+	Generation uint64 `json:"generation"`
+	Hash       string `json:"hash"`
+	// Files are the settings files that were loaded and EnvFiles the .env
+	// files, lowest precedence first.
+	Files     []string           `json:"files"`
+	EnvFiles  []string           `json:"envFiles"`
+	Settings  []ReportedSetting  `json:"settings"`
+	Requested []RequestedSetting `json:"requested"`
+}
+
+// ReportedSetting is the effective value of a key and how often it has been
+// requested.
+type ReportedSetting struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Source   string `json:"source"`
+	Requests int64  `json:"requests"`
+}
+
+// RequestedSetting is a key that has been requested, with the type and
+// default value it was requested with.
+type RequestedSetting struct {
+	Key            string    `json:"key"`
+	Type           string    `json:"type"`
+	Value          string    `json:"value"`
+	Source         string    `json:"source"`
+	HasDefault     bool      `json:"hasDefault"`
+	Default        string    `json:"default,omitempty"`
+	FirstRequested time.Time `json:"firstRequested"`
+	LastRequested  time.Time `json:"lastRequested"`
+	Count          int64     `json:"count"`
+	Callers        []string  `json:"callers"`
+}
+
+// ReportFilter limits a ConfigReport to some keys.  A key is included if it
+// starts with any of KeyPrefixes and its value came from any of Sources.
+// Empty lists match everything.
+//
+// A source matches its label, such as ENV or SECRET_FILE, including the
+// variants such as ENV:APP_URL.  FILE matches values from the settings files
+// and DEFAULT matches requests that fell back to their default value.
+// Sources are matched case insensitively.
+type ReportFilter struct {
+	KeyPrefixes []string
+	Sources     []string
+}
+
+func (f ReportFilter) matchesKey(key string) bool {
+	if len(f.KeyPrefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range f.KeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f ReportFilter) matchesSource(c *Configuration, source string) bool {
+	if len(f.Sources) == 0 {
+		return true
+	}
+
+	for _, want := range f.Sources {
+		switch {
+		case strings.EqualFold(want, "FILE"):
+			if c.isFileSource(source) {
+				return true
+			}
+		case strings.EqualFold(source, want):
+			return true
+		case len(source) > len(want) && source[len(want)] == ':' && strings.EqualFold(source[:len(want)], want):
+			return true
+		}
+	}
+
+	return false
+}
+
+// Report returns the settings, the requested settings and what was loaded,
+// for the keys that match filter.  The settings, generation and hash come
+// from a single snapshot, so they are consistent with each other.
+func (c *Configuration) Report(filter ReportFilter) ConfigReport {
+	c = c.root()
+
+	snap := c.Snapshot()
+	counts := c.requestCountByKey()
+
+	c.mu.RLock()
+	report := ConfigReport{
+		Context:    c.context,
+		App:        c.app,
+		Generation: snap.Generation(),
+		Hash:       snap.Hash(),
+		Files:      append([]string{}, c.files...),
+		EnvFiles:   []string{},
+		Settings:   []ReportedSetting{},
+		Requested:  []RequestedSetting{},
+	}
+	if c.dotenv != nil {
+		report.EnvFiles = append(report.EnvFiles, c.dotenv.files...)
+	}
+	c.mu.RUnlock()
+
+	for _, s := range snap.cfg.settingsSnapshot() {
+		if !filter.matchesKey(s.Key) || !filter.matchesSource(snap.cfg, s.Source) {
+			continue
+		}
+
+		report.Settings = append(report.Settings, ReportedSetting{
+			Key:      s.Key,
+			Value:    s.Value,
+			Source:   s.Source,
+			Requests: counts[s.Key],
+		})
+	}
+
+	for _, rq := range c.requestedSnapshot() {
+		if !filter.matchesKey(rq.Key) || !filter.matchesSource(c, rq.Source) {
+			continue
+		}
+
+		report.Requested = append(report.Requested, RequestedSetting{
+			Key:            rq.Key,
+			Type:           rq.Type,
+			Value:          rq.Value,
+			Source:         rq.Source,
+			HasDefault:     rq.HasDefault,
+			Default:        rq.DefaultValue,
+			FirstRequested: rq.FirstRequested,
+			LastRequested:  rq.LastRequested,
+			Count:          rq.Count,
+			Callers:        rq.Callers,
+		})
+	}
+
+	return report
+}
+
+// HandleConfigJSON serves the configuration report as JSON.  The prefix and
+// source query parameters filter it, and can be repeated, e.g.
+// config.json?prefix=db_&source=ENV&source=FILE.
+func HandleConfigJSON(w http.ResponseWriter, r *http.Request) {
+	Config().handleConfigJSON(w, r)
+}
+
+func (c *Configuration) handleConfigJSON(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	report := c.Report(ReportFilter{
+		KeyPrefixes: query["prefix"],
+		Sources:     query["source"],
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+}
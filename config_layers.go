@@ -0,0 +1,408 @@
+package gocore
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Layer priorities.  Layers are consulted in descending priority order.  The
+// process environment is not a layer, but it sits at priorityEnv so that
+// other layers can rank above or below it.
+const (
+	priorityOverride    = 300
+	priorityCmdlineHigh = 200
+	priorityEnv         = 100
+	priorityCmdlineLow  = 90
+	prioritySecretFile  = 40
+	priorityRemote      = 30
+)
+
+// Provenance labels for the layers.
+const (
+	sourceOverride   = "OVERRIDE"
+	sourceCmdline    = "CMDLINE"
+	sourceSecretFile = "SECRET_FILE"
+	sourceRemote     = "REMOTE"
+)
+
+// layer is a named set of settings that is consulted before the settings
+// files.  Keys are resolved using the same context/app fallback as the
+// settings files.
+type layer struct {
+	name     string
+	priority int
+	secret   bool
+	mu       sync.RWMutex
+	values   map[string]string
+}
+
+// layerList is the list of layers, in descending priority order.  It is
+// shared with the configurations for alternative contexts, so that layers
+// added later reach them as well.
+type layerList struct {
+	mu     sync.RWMutex
+	layers []*layer
+}
+
+func newLayer(name string, priority int, secret bool) *layer {
+	return &layer{
+		name:     name,
+		priority: priority,
+		secret:   secret,
+		values:   make(map[string]string),
+	}
+}
+
+// replace swaps the contents of the layer and returns the keys that changed
+// along with their new values.  Removed keys are reported with an empty value.
+func (l *layer) replace(values map[string]string) map[string]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	changed := make(map[string]string)
+
+	for k, v := range values {
+		if old, found := l.values[k]; !found || old != v {
+			changed[k] = v
+		}
+	}
+
+	for k := range l.values {
+		if _, found := values[k]; !found {
+			changed[k] = ""
+		}
+	}
+
+	l.values = values
+
+	return changed
+}
+
+// lookup returns the first candidate key that is present in the layer.
+func (l *layer) lookup(candidates []string) (string, string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, k := range candidates {
+		if v, ok := l.values[k]; ok {
+			return v, k, true
+		}
+	}
+
+	return "", "", false
+}
+
+func (l *layer) keys() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	keys := make([]string, 0, len(l.values))
+	for k := range l.values {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// source returns the provenance label for a value found under keyUsed when
+// key was requested.
+func (l *layer) source(key, keyUsed string) string {
+	if keyUsed == key {
+		return l.name
+	}
+
+	return l.name + ":" + keyUsed
+}
+
+// addLayer adds a layer to the configuration, replacing any existing layer
+// with the same name.
+func (c *Configuration) addLayer(l *layer) {
+	list := c.layerList()
+
+	list.mu.Lock()
+	defer list.mu.Unlock()
+
+	layers := make([]*layer, 0, len(list.layers)+1)
+	for _, existing := range list.layers {
+		if existing.name != l.name {
+			layers = append(layers, existing)
+		}
+	}
+	layers = append(layers, l)
+
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].priority > layers[j].priority
+	})
+
+	list.layers = layers
+}
+
+// replaceLayer sets the contents of the named layer, adding it if it does not
+// exist yet, and notifies listeners of any keys that changed in an existing
+// layer.
+func (c *Configuration) replaceLayer(name string, priority int, secret bool, values map[string]string) {
+	c.changeMu.Lock()
+
+	var l *layer
+	for _, existing := range c.getLayers() {
+		if existing.name == name {
+			l = existing
+		}
+	}
+
+	var current map[string]string
+	if l != nil {
+		current = l.clone().values
+	}
+	values = c.validateLayer(name, current, values)
+
+	var changed map[string]string
+	if l == nil {
+		l = newLayer(name, priority, secret)
+		l.values = values
+		c.addLayer(l)
+		c.generation.Add(1)
+		changed = values
+	} else if changed = l.replace(values); len(changed) > 0 {
+		c.generation.Add(1)
+	}
+
+	// Listeners are called after the change is complete, so that they can
+	// take a snapshot
+	c.changeMu.Unlock()
+
+	for key, value := range changed {
+		c.notifyListeners(key, value)
+	}
+}
+
+// SetOverrides replaces the overrides, a layer of settings that takes
+// precedence over everything else, including the environment and the command
+// line.  It is meant for tests, which can use the gocoretest package to set
+// overrides on a copy of the configuration.  Keys are resolved with the usual
+// context and application fallback, and a nil or empty map removes all
+// overrides.
+func (c *Configuration) SetOverrides(values map[string]string) {
+	copied := make(map[string]string, len(values))
+	for k, v := range values {
+		copied[c.qualify(k)] = v
+	}
+
+	c.root().replaceLayer(sourceOverride, priorityOverride, false, copied)
+}
+
+// clone returns a copy of the layer that does not change with it.
+func (l *layer) clone() *layer {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	values := make(map[string]string, len(l.values))
+	for k, v := range l.values {
+		values[k] = v
+	}
+
+	return &layer{name: l.name, priority: l.priority, secret: l.secret, values: values}
+}
+
+// layerList returns the list of layers, creating it if necessary.
+func (c *Configuration) layerList() *layerList {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.layers == nil {
+		c.layers = &layerList{}
+	}
+
+	return c.layers
+}
+
+func (c *Configuration) getLayers() []*layer {
+	c.mu.RLock()
+	list := c.layers
+	c.mu.RUnlock()
+
+	if list == nil {
+		return nil
+	}
+
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+
+	return list.layers
+}
+
+// lookupLayers searches the layers whose priority is within (min, max] for
+// the first of candidates, and returns the value, the provenance label, the
+// key that supplied the value and whether it was found.
+func (c *Configuration) lookupLayers(key string, candidates []string, min, max int) (string, string, string, bool) {
+	for _, l := range c.getLayers() {
+		if l.priority <= min || l.priority > max {
+			continue
+		}
+
+		if v, keyUsed, ok := l.lookup(candidates); ok {
+			return v, l.source(key, keyUsed), keyUsed, true
+		}
+	}
+
+	return "", "", "", false
+}
+
+// candidateKeys returns the keys that are tried, most specific first, when
+// key is resolved for the current context and application.
+func (c *Configuration) candidateKeys(key string) []string {
+	k := key
+	if c.context != "" {
+		k += "." + c.context
+	}
+
+	var keys []string
+
+	for {
+		if c.app != "" {
+			keys = append(keys, k+"."+c.app)
+		}
+		keys = append(keys, k)
+
+		pos := strings.LastIndex(k, ".")
+		if pos == -1 {
+			break
+		}
+		k = k[:pos]
+	}
+
+	return keys
+}
+
+// contextNames returns the names that are treated as context or application
+// suffixes in keys: the parts of the current context, the application, and
+// any name that ends at least two keys, one of which extends another key,
+// such as live in host.live and port.live when host is also set.  A name that
+// ends only one key, such as brokers in kafka.brokers, is taken to be part of
+// the key.
+func (c *Configuration) contextNames() map[string]struct{} {
+	c = c.root()
+
+	names := make(map[string]struct{})
+	if c.context != "" {
+		for _, name := range strings.Split(c.context, ".") {
+			names[name] = struct{}{}
+		}
+	}
+	if c.app != "" {
+		names[c.app] = struct{}{}
+	}
+
+	declared := make(map[string]struct{})
+	for _, k := range c.allKeys() {
+		declared[k] = struct{}{}
+	}
+
+	counts := make(map[string]int)
+	extends := make(map[string]bool)
+	for k := range declared {
+		pos := strings.LastIndex(k, ".")
+		if pos == -1 {
+			continue
+		}
+
+		name := k[pos+1:]
+		counts[name]++
+		if _, found := declared[k[:pos]]; found {
+			extends[name] = true
+		}
+	}
+
+	for name, n := range counts {
+		if n > 1 && extends[name] {
+			names[name] = struct{}{}
+		}
+	}
+
+	return names
+}
+
+// baseKey removes any context or application suffix from key, starting at
+// the first part after the first that is in names, so that host.live.uk and
+// name.live.eupriv become host and name.
+func baseKey(key string, names map[string]struct{}) string {
+	parts := strings.Split(key, ".")
+
+	for i := 1; i < len(parts); i++ {
+		if _, found := names[parts[i]]; found {
+			return strings.Join(parts[:i], ".")
+		}
+	}
+
+	return key
+}
+
+// isSecretSource reports whether values from the given provenance label must
+// always be masked.
+func isSecretSource(source string) bool {
+	return source == sourceSecretFile || strings.HasPrefix(source, sourceSecretFile+":")
+}
+
+// maskSetting is maskValue for the value of key, which is also masked if it
+// interpolates a secret, such as ${db_password} from the secrets directory.
+func (c *Configuration) maskSetting(key, value, source string) string {
+	if c.interpolatesSecret(key) {
+		return eheMask
+	}
+
+	return maskValue(value, source)
+}
+
+// interpolatesSecret reports whether the raw value of key refers to a secret.
+func (c *Configuration) interpolatesSecret(key string) bool {
+	raw, ok, _ := c.resolveRaw(key)
+
+	return ok && c.refersToSecret(raw)
+}
+
+// refersToSecret reports whether raw refers to a key that is a secret.
+func (c *Configuration) refersToSecret(raw string) bool {
+	for _, match := range reVariable.FindAllStringSubmatch(raw, -1) {
+		if c.isSecretKey(match[1], 1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSecretKey reports whether the value of key comes from a secret source, is
+// encrypted or refers to another key that is a secret.
+func (c *Configuration) isSecretKey(key string, depth int) bool {
+	raw, ok, source := c.resolveRaw(key)
+	if !ok {
+		return false
+	}
+
+	if isSecretSource(source) || strings.Contains(raw, "*EHE*") {
+		return true
+	}
+
+	if depth > 10 {
+		return false
+	}
+
+	for _, match := range reVariable.FindAllStringSubmatch(raw, -1) {
+		if c.isSecretKey(match[1], depth+1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maskValue masks value if it came from a secret source or contains any
+// encrypted (EHE) parts.
+func maskValue(value, source string) string {
+	if isSecretSource(source) {
+		return eheMask
+	}
+
+	return maskSecrets(value)
+}
@@ -0,0 +1,276 @@
+package gocore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// remoteConfig pulls settings for one service, context and application from
+// an HTTP config service.
+type remoteConfig struct {
+	url       string
+	cacheFile string
+	client    *http.Client
+
+	mu   sync.Mutex
+	etag string
+}
+
+// remoteCache is the copy of the last good response that is kept on disk so
+// that the service can start when the config service is unreachable.
+type remoteCache struct {
+	ETag   string            `json:"etag"`
+	Values map[string]string `json:"values"`
+}
+
+// newRemoteConfig returns a remoteConfig for baseURL, adding the service,
+// context and application to its query string.
+func (c *Configuration) newRemoteConfig(baseURL, service, cacheFile string) (*remoteConfig, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set("service", service)
+	q.Set("context", c.context)
+	if c.app != "" {
+		q.Set("app", c.app)
+	}
+	u.RawQuery = q.Encode()
+
+	return &remoteConfig{
+		url:       u.String(),
+		cacheFile: cacheFile,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+// defaultRemoteCacheFile returns where the last good remote settings are
+// kept when remoteConfigCacheFile is not set.
+func (c *Configuration) defaultRemoteCacheFile(service string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	name := service + "-" + c.context
+	if c.app != "" {
+		name += "-" + c.app
+	}
+
+	return filepath.Join(dir, "gocore", name+".json")
+}
+
+// fetch requests the settings, sending the ETag of the last response so that
+// the config service can answer 304 Not Modified, in which case changed is
+// false.
+func (r *remoteConfig) fetch() (values map[string]string, changed bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	r.mu.Lock()
+	etag := r.etag
+	r.mu.Unlock()
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, false, nil
+	case http.StatusOK:
+	default:
+		return nil, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	values, err = parseRemoteValues(body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	r.mu.Lock()
+	r.etag = resp.Header.Get("ETag")
+	r.mu.Unlock()
+
+	return values, true, nil
+}
+
+// parseRemoteValues reads a flat JSON object of settings.  Numbers and
+// booleans are accepted as well as strings, and null values are ignored.
+func parseRemoteValues(b []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid remote settings: %w", err)
+	}
+
+	values := make(map[string]string, len(raw))
+
+	for k, v := range raw {
+		switch v := v.(type) {
+		case nil:
+			continue
+		case string:
+			values[k] = v
+		case json.Number:
+			values[k] = v.String()
+		case bool:
+			values[k] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("invalid remote settings: %q is not a string, number or boolean", k)
+		}
+	}
+
+	return values, nil
+}
+
+// loadRemote refreshes the REMOTE layer from the config service and caches
+// the response on disk.  Listeners are notified of any keys that changed.
+func (c *Configuration) loadRemote(r *remoteConfig) error {
+	values, changed, err := r.fetch()
+	if err != nil || !changed {
+		return err
+	}
+
+	c.replaceLayer(sourceRemote, priorityRemote, false, values)
+
+	if r.cacheFile != "" {
+		r.mu.Lock()
+		etag := r.etag
+		r.mu.Unlock()
+
+		if err := writeRemoteCache(r.cacheFile, remoteCache{ETag: etag, Values: values}); err != nil {
+			log.Printf("WARN: Failed to cache remote settings in '%s' - [%v]", r.cacheFile, err)
+		}
+	}
+
+	return nil
+}
+
+// loadRemoteCache fills the REMOTE layer from the cached copy of the last
+// good response.  The cached ETag is sent with the next request, so the
+// config service only needs to send the settings again if they changed.
+func (c *Configuration) loadRemoteCache(r *remoteConfig) error {
+	b, err := os.ReadFile(r.cacheFile)
+	if err != nil {
+		return err
+	}
+
+	var cache remoteCache
+	if err := json.Unmarshal(b, &cache); err != nil {
+		return err
+	}
+
+	if cache.Values == nil {
+		cache.Values = make(map[string]string)
+	}
+
+	c.replaceLayer(sourceRemote, priorityRemote, false, cache.Values)
+
+	r.mu.Lock()
+	r.etag = cache.ETag
+	r.mu.Unlock()
+
+	return nil
+}
+
+// writeRemoteCache writes the cache through a temporary file so that a
+// crash never leaves a truncated copy behind.
+func writeRemoteCache(filename string, cache remoteCache) error {
+	b, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filename)
+}
+
+// watchRemote reads the settings from the config service straight away and
+// then polls it every interval.
+func (c *Configuration) watchRemote(r *remoteConfig, interval time.Duration) {
+	go func() {
+		if err := c.loadRemote(r); err != nil {
+			log.Printf("WARN: Failed to read remote settings from '%s' - [%v]", r.url, err)
+		} else {
+			logInfof("INFO: Loaded remote settings from '%s'", r.url)
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := c.loadRemote(r); err != nil {
+				log.Printf("WARN: Failed to read remote settings from '%s' - [%v]", r.url, err)
+			}
+		}
+	}()
+}
+
+// startRemoteConfig loads the remote settings layer from remoteConfigURL and
+// keeps it up to date.  Config must not wait for the network, so the cached
+// copy of the last good response is loaded first and the config service is
+// asked in the background.  Listeners are notified of the settings that its
+// answer changes.
+func (c *Configuration) startRemoteConfig(baseURL string) {
+	service, _ := c.Get("remoteConfigService", GetPackageName())
+
+	cacheFile, _ := c.Get("remoteConfigCacheFile", c.defaultRemoteCacheFile(service))
+
+	r, err := c.newRemoteConfig(baseURL, service, cacheFile)
+	if err != nil {
+		log.Printf("WARN: Invalid remoteConfigURL %q - [%v]", baseURL, err)
+		return
+	}
+
+	if err := c.loadRemoteCache(r); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("WARN: Failed to read cached remote settings from '%s' - [%v]", cacheFile, err)
+		}
+	} else {
+		logInfof("INFO: Loaded cached remote settings from '%s'", cacheFile)
+	}
+
+	pollInterval, _ := c.Get("remoteConfigPollInterval", "1m")
+	interval, err := time.ParseDuration(pollInterval)
+	if err != nil {
+		interval = time.Minute
+	}
+
+	c.watchRemote(r, interval)
+}
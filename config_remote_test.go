@@ -0,0 +1,170 @@
+package gocore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteServer serves body with an ETag and answers 304 when the client
+// already has it.
+type remoteServer struct {
+	mu       sync.Mutex
+	body     string
+	etag     string
+	requests []*http.Request
+}
+
+func (s *remoteServer) set(body, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.body = body
+	s.etag = etag
+}
+
+func (s *remoteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r)
+
+	if r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("ETag", s.etag)
+	_, _ = w.Write([]byte(s.body))
+}
+
+func TestRemoteConfig(t *testing.T) {
+	srv := &remoteServer{body: `{"db_host": "db.remote", "db_port": 5433, "debug": true, "unset": null}`, etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	cfg := newTestConfig(t, "live", "db_host = localhost\nname = app\n")
+	cfg.app = "api"
+
+	r, err := cfg.newRemoteConfig(ts.URL+"/config?team=core", "orders", filepath.Join(t.TempDir(), "cache.json"))
+	require.NoError(t, err)
+	require.NoError(t, cfg.loadRemote(r))
+
+	q := srv.requests[0].URL.Query()
+	assert.Equal(t, "core", q.Get("team"))
+	assert.Equal(t, "orders", q.Get("service"))
+	assert.Equal(t, "live", q.Get("context"))
+	assert.Equal(t, "api", q.Get("app"))
+
+	v, ok := cfg.Get("db_host")
+	assert.True(t, ok)
+	assert.Equal(t, "db.remote", v)
+
+	port, _ := cfg.GetInt("db_port")
+	assert.Equal(t, 5433, port)
+	assert.True(t, cfg.GetBool("debug"))
+
+	_, ok = cfg.Get("unset")
+	assert.False(t, ok)
+
+	_, _, source := cfg.getInternal("db_host")
+	assert.Equal(t, sourceRemote, source)
+	assert.Contains(t, cfg.Stats(), "db_host[REMOTE]=db.remote\n")
+
+	listener := newMockListener(3)
+	cfg.AddListener(listener)
+
+	// Unchanged settings are not sent again
+	require.NoError(t, cfg.loadRemote(r))
+	assert.Equal(t, `"v1"`, srv.requests[1].Header.Get("If-None-Match"))
+	assert.Empty(t, listener.ch)
+
+	srv.set(`{"db_host": "db2.remote"}`, `"v2"`)
+	require.NoError(t, cfg.loadRemote(r))
+
+	v, _ = cfg.Get("db_host")
+	assert.Equal(t, "db2.remote", v)
+
+	assert.ElementsMatch(t, []string{"db_host=db2.remote", "db_port=", "debug="}, []string{<-listener.ch, <-listener.ch, <-listener.ch})
+}
+
+func TestRemoteConfigCache(t *testing.T) {
+	srv := &remoteServer{body: `{"token": "abc"}`, etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+
+	cacheFile := filepath.Join(t.TempDir(), "gocore", "cache.json")
+
+	cfg := NewConfiguration("dev", "")
+	r, err := cfg.newRemoteConfig(ts.URL, "orders", cacheFile)
+	require.NoError(t, err)
+	require.NoError(t, cfg.loadRemote(r))
+
+	ts.Close()
+
+	// A new process starts while the config service is down
+	offline := NewConfiguration("dev", "")
+	r, err = offline.newRemoteConfig(ts.URL, "orders", cacheFile)
+	require.NoError(t, err)
+
+	require.Error(t, offline.loadRemote(r))
+	require.NoError(t, offline.loadRemoteCache(r))
+
+	v, ok := offline.Get("token")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", v)
+	assert.Equal(t, `"v1"`, r.etag)
+}
+
+func TestStartRemoteConfig(t *testing.T) {
+	srv := &remoteServer{body: `{"token": "fresh"}`, etag: `"v2"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	cacheFile := filepath.Join(t.TempDir(), "cache.json")
+	require.NoError(t, writeRemoteCache(cacheFile, remoteCache{ETag: `"v1"`, Values: map[string]string{"token": "cached"}}))
+
+	cfg := newTestConfig(t, "dev", "remoteConfigCacheFile = "+cacheFile+"\nremoteConfigPollInterval = 1h\n")
+
+	// The config service does not answer until it is unlocked, but the
+	// cached settings are there straight away
+	srv.mu.Lock()
+	cfg.startRemoteConfig(ts.URL)
+
+	v, _ := cfg.Get("token")
+	assert.Equal(t, "cached", v)
+
+	listener := newMockListener(1)
+	cfg.AddListener(listener)
+	srv.mu.Unlock()
+
+	select {
+	case change := <-listener.ch:
+		assert.Equal(t, "token=fresh", change)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the remote settings were not loaded")
+	}
+
+	v, _ = cfg.Get("token")
+	assert.Equal(t, "fresh", v)
+}
+
+func TestRemoteConfigErrors(t *testing.T) {
+	srv := &remoteServer{body: `{"nested": {"a": 1}}`, etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	cfg := NewConfiguration("dev", "")
+	r, err := cfg.newRemoteConfig(ts.URL, "orders", "")
+	require.NoError(t, err)
+
+	assert.EqualError(t, cfg.loadRemote(r), `invalid remote settings: "nested" is not a string, number or boolean`)
+
+	ts.Config.Handler = http.NotFoundHandler()
+	assert.EqualError(t, cfg.loadRemote(r), "unexpected status 404 Not Found")
+}
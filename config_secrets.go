@@ -0,0 +1,74 @@
+package gocore
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// readSecretsDir reads a directory of mounted secrets, as used by Docker and
+// Kubernetes, where each file holds one setting.  The filename is the key and
+// the trimmed file contents are the value.  Hidden files (such as the
+// Kubernetes ..data symlink) and directories are ignored.
+func readSecretsDir(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(entries))
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+
+		// Stat follows symlinks, which is how Kubernetes projects secret files
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		values[name] = strings.TrimSpace(string(b))
+	}
+
+	return values, nil
+}
+
+// loadSecretsDir adds (or refreshes) the SECRET_FILE layer from dir and
+// notifies listeners of any keys that changed.
+func (c *Configuration) loadSecretsDir(dir string) error {
+	values, err := readSecretsDir(dir)
+	if err != nil {
+		return err
+	}
+
+	c.replaceLayer(sourceSecretFile, prioritySecretFile, true, values)
+
+	return nil
+}
+
+// watchSecretsDir re-reads the secrets directory every interval so that
+// rotated secrets are picked up without a restart.
+func (c *Configuration) watchSecretsDir(dir string, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := c.loadSecretsDir(dir); err != nil {
+				log.Printf("WARN: Failed to read secrets directory '%s' - [%v]", dir, err)
+			}
+		}
+	}()
+}
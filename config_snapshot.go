@@ -0,0 +1,181 @@
+package gocore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snapshot is a view of the settings at one generation.  Reading several
+// related keys from a snapshot guarantees that they are consistent with each
+// other, even if Set is called in between.  A Snapshot is cheap to copy and
+// safe for concurrent use, and reads through it are recorded as requests on
+// the configuration it was taken from.
+//
+// The settings files, layers and runtime changes are captured when the
+// snapshot is taken, but the process environment is still read on each
+// lookup, so a snapshot sees changes made with os.Setenv.
+type Snapshot struct {
+	cfg        *Configuration
+	generation uint64
+	hash       *snapshotHash
+}
+
+type snapshotHash struct {
+	once  sync.Once
+	value string
+}
+
+// Generation returns a number that increases every time a setting changes,
+// whether through Set, Unset, Revert, a reload of a settings layer or
+// AddSettings.
+func (c *Configuration) Generation() uint64 {
+	c = c.root()
+
+	return c.generation.Load()
+}
+
+// Snapshot returns a consistent view of the settings at the current
+// generation.  Snapshots taken at the same generation share their settings
+// and hash.  Snapshots of a view returned by Sub cover the whole
+// configuration, so keys must be given in full.
+func (c *Configuration) Snapshot() Snapshot {
+	c = c.root()
+
+	c.changeMu.RLock()
+	defer c.changeMu.RUnlock()
+
+	// Nothing can change while changeMu is held, so a snapshot of the
+	// current generation can be shared rather than copying everything again
+	generation := c.generation.Load()
+	if cached := c.snapshot.Load(); cached != nil && cached.generation == generation {
+		return *cached
+	}
+
+	frozen := c.copyAt(c.context)
+	frozen.recordTo = c
+
+	snap := Snapshot{
+		cfg:        frozen,
+		generation: generation,
+		hash:       &snapshotHash{},
+	}
+	c.snapshot.Store(&snap)
+
+	return snap
+}
+
+// Clone returns an independent copy of the configuration, resolved for
+// alternativeContext if one is given.  The copy starts with the same
+// settings, layers, aliases and validators, but changes made to either one
+// afterwards, including reloads of the secrets directory or remote settings,
+// do not affect the other.  Listeners and requested settings are not copied.
+// Cloning a view returned by Sub clones the whole configuration and returns
+// the same view of the copy.
+func (c *Configuration) Clone(alternativeContext ...string) *Configuration {
+	if prefix := c.fullPrefix(); prefix != "" {
+		return c.root().Clone(alternativeContext...).Sub(prefix)
+	}
+
+	context := c.context
+	if len(alternativeContext) > 0 {
+		context = alternativeContext[0]
+	}
+
+	c.changeMu.RLock()
+	defer c.changeMu.RUnlock()
+
+	cp := c.copyAt(context)
+	cp.aliases = c.aliases.clone()
+
+	return cp
+}
+
+// copyAt returns a copy of c resolved for context, with its own copy of the
+// layers and origins.  The caller must hold c.changeMu.
+func (c *Configuration) copyAt(context string) *Configuration {
+	cp := c.withContext(context)
+
+	layers := c.getLayers()
+	cp.layers = &layerList{layers: make([]*layer, len(layers))}
+	for i, l := range layers {
+		cp.layers.layers[i] = l.clone()
+	}
+
+	c.mu.RLock()
+	cp.origins = make(map[string]string, len(c.origins))
+	for k, v := range c.origins {
+		cp.origins[k] = v
+	}
+	cp.validators = c.validators.clone()
+	if c.overridden != nil {
+		cp.overridden = make(map[string]originalValue, len(c.overridden))
+		for k, v := range c.overridden {
+			cp.overridden[k] = v
+		}
+	}
+	cp.cmdlineBelowEnv = c.cmdlineBelowEnv
+	c.mu.RUnlock()
+
+	return cp
+}
+
+// Generation returns the generation the snapshot was taken at.
+func (s Snapshot) Generation() uint64 {
+	return s.generation
+}
+
+// Hash returns a hash of every effective setting in the snapshot, which is
+// the same for two instances with the same configuration.  Encrypted values
+// are hashed in their encrypted form and values from the secrets directory
+// are masked, so the hash reveals nothing about them.
+func (s Snapshot) Hash() string {
+	s.hash.once.Do(func() {
+		all := s.cfg.GetAll()
+
+		keys := make([]string, 0, len(all))
+		for k := range all {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		h := sha256.New()
+		for _, k := range keys {
+			_, _ = h.Write([]byte(k))
+			_, _ = h.Write([]byte{'='})
+			_, _ = h.Write([]byte(all[k]))
+			_, _ = h.Write([]byte{'\n'})
+		}
+
+		s.hash.value = hex.EncodeToString(h.Sum(nil))
+	})
+
+	return s.hash.value
+}
+
+// Get returns the value of key in the snapshot, as Configuration.Get does.
+func (s Snapshot) Get(key string, defaultValue ...string) (string, bool) {
+	return s.cfg.Get(key, defaultValue...)
+}
+
+// GetInt returns the value of key in the snapshot as an int.
+func (s Snapshot) GetInt(key string, defaultValue ...int) (int, bool) {
+	return s.cfg.GetInt(key, defaultValue...)
+}
+
+// GetBool returns the value of key in the snapshot as a bool.
+func (s Snapshot) GetBool(key string, defaultValue ...bool) bool {
+	return s.cfg.GetBool(key, defaultValue...)
+}
+
+// GetDuration returns the value of key in the snapshot as a time.Duration.
+func (s Snapshot) GetDuration(key string, defaultValue ...time.Duration) (time.Duration, error, bool) {
+	return s.cfg.GetDuration(key, defaultValue...)
+}
+
+// GetAll returns every setting in the snapshot, as Configuration.GetAll does.
+func (s Snapshot) GetAll() map[string]string {
+	return s.cfg.GetAll()
+}
@@ -0,0 +1,214 @@
+package gocore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	cfg := newTestConfig(t, "live", "host = localhost\nhost.live = db.live\nport = 5432\nurl = ${host}:${port}\n")
+	cfg.SetCommandLineSettings(map[string]string{"timeout": "5s"})
+
+	snap := cfg.Snapshot()
+	generation := cfg.Generation()
+	assert.Equal(t, generation, snap.Generation())
+
+	cfg.Set("host.live", "db2.live")
+	cfg.Unset("port")
+	cfg.SetCommandLineSettings(map[string]string{"timeout": "10s"})
+
+	assert.Equal(t, generation+3, cfg.Generation())
+
+	// The snapshot still sees the settings as they were
+	host, _ := snap.Get("host")
+	assert.Equal(t, "db.live", host)
+
+	port, ok := snap.GetInt("port")
+	assert.True(t, ok)
+	assert.Equal(t, 5432, port)
+
+	url, _ := snap.Get("url")
+	assert.Equal(t, "db.live:5432", url)
+
+	timeout, err, _ := snap.GetDuration("timeout")
+	require.NoError(t, err)
+	assert.Equal(t, "5s", timeout.String())
+
+	host, _ = cfg.Get("host")
+	assert.Equal(t, "db2.live", host)
+
+	// Reverting is a change too
+	cfg.Revert("port")
+	assert.Equal(t, generation+4, cfg.Generation())
+
+	// Layers that are reloaded without changes do not move the generation
+	cfg.SetCommandLineSettings(map[string]string{"timeout": "10s"})
+	assert.Equal(t, generation+4, cfg.Generation())
+}
+
+func TestSnapshotHash(t *testing.T) {
+	load := func() *Configuration {
+		return newTestConfig(t, "dev", "a = 1\nb = 2\n")
+	}
+
+	one, two := load(), load()
+	two.Set("c", "3")
+	two.Unset("c")
+
+	// Different histories, same configuration
+	assert.NotEqual(t, one.Generation(), two.Generation())
+	assert.Equal(t, one.Snapshot().Hash(), two.Snapshot().Hash())
+	assert.Len(t, one.Snapshot().Hash(), 64)
+
+	two.Set("b", "3")
+	assert.NotEqual(t, one.Snapshot().Hash(), two.Snapshot().Hash())
+}
+
+func TestSnapshotConcurrentChanges(t *testing.T) {
+	cfg := NewConfiguration("dev", "")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			cfg.Set("counter", fmt.Sprint(i))
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			snap := cfg.Snapshot()
+			v, ok := snap.Get("counter")
+			if snap.Generation() == 0 {
+				assert.False(t, ok)
+			} else {
+				assert.Equal(t, fmt.Sprint(snap.Generation()-1), v)
+			}
+		}
+	}()
+
+	wg.Wait()
+}
+
+func TestClone(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "url = http://localhost\nurl.live = https://example.com\nold_name = legacy\n")
+	cfg.Alias("old_name", "name", "use name")
+	cfg.AddValidator("url", func(key, newValue string) error {
+		if newValue == "" {
+			return fmt.Errorf("url is required")
+		}
+		return nil
+	})
+
+	live := cfg.Clone("live")
+	assert.Equal(t, "live", live.GetContext())
+
+	v, _ := live.Get("url")
+	assert.Equal(t, "https://example.com", v)
+
+	v, _ = live.Get("name")
+	assert.Equal(t, "legacy", v)
+
+	_, err := live.TryUnset("url.live")
+	assert.Error(t, err)
+
+	live.Set("url.live", "https://live.example.com")
+	live.Alias("new_alias", "other", "")
+
+	v, _ = cfg.Clone("live").Get("url")
+	assert.Equal(t, "https://example.com", v)
+	_, ok := cfg.Get("other")
+	assert.False(t, ok)
+
+	sub := cfg.Sub("url").Clone()
+	assert.Equal(t, "dev", sub.GetContext())
+}
+
+func TestSetOverrides(t *testing.T) {
+	t.Setenv("override_port", "9000")
+
+	cfg := newTestConfig(t, "live", "override_port = 8000\n")
+
+	clone := cfg.Clone()
+	clone.SetOverrides(map[string]string{"override_port": "7000", "override_host.live": "test"})
+
+	port, _ := clone.GetInt("override_port")
+	assert.Equal(t, 7000, port)
+
+	host, _ := clone.Get("override_host")
+	assert.Equal(t, "test", host)
+	assert.Contains(t, clone.Stats(), "override_host[OVERRIDE:override_host.live]=test\n")
+
+	port, _ = cfg.GetInt("override_port")
+	assert.Equal(t, 9000, port)
+
+	clone.SetOverrides(nil)
+	port, _ = clone.GetInt("override_port")
+	assert.Equal(t, 9000, port)
+}
+
+// snapshotListener takes a snapshot from inside the listener callback.
+type snapshotListener struct {
+	cfg    *Configuration
+	values []string
+}
+
+func (l *snapshotListener) UpdateSetting(key, _ string) {
+	v, _ := l.cfg.Snapshot().Get(key)
+	l.values = append(l.values, v)
+}
+
+func TestSnapshotFromListener(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "a = 1\n")
+
+	listener := &snapshotListener{cfg: cfg}
+	cfg.AddListener(listener)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cfg.Set("a", "2")
+		cfg.Unset("a")
+		cfg.Revert("a")
+		cfg.SetOverrides(map[string]string{"a": "3"})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a listener that takes a snapshot deadlocked")
+	}
+
+	// Each listener sees the change it is told about
+	assert.Equal(t, []string{"2", "", "1", "3"}, listener.values)
+}
+
+func TestSnapshotRecordsRequests(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "a = 1\n")
+
+	snap := cfg.Snapshot()
+	_, _ = snap.GetInt("a")
+	_, _ = snap.Get("b", "x")
+
+	requested := cfg.requestedSnapshot()
+	require.Len(t, requested, 2)
+	assert.Equal(t, "a", requested[0].Key)
+	assert.Equal(t, "int", requested[0].Type)
+	require.Len(t, requested[0].Callers, 1)
+	assert.Contains(t, requested[0].Callers[0], "config_snapshot_test.go")
+	assert.Equal(t, "b", requested[1].Key)
+
+	// Snapshots are shared until something changes
+	assert.Same(t, snap.cfg, cfg.Snapshot().cfg)
+
+	cfg.Set("a", "2")
+	assert.NotSame(t, snap.cfg, cfg.Snapshot().cfg)
+}
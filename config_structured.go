@@ -0,0 +1,286 @@
+package gocore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetMap returns a map setting.  A map can be written in one of two forms:
+//
+//	peers = alice=http://a:8080; bob=http://b:8080
+//
+// or as one key per entry, each of which can have its own context variants:
+//
+//	peers.alice      = http://a:8080
+//	peers.alice.live = https://a.example.com
+//	peers.bob        = http://b:8080
+//
+// The single value form is used if key resolves to a value.  Otherwise the
+// entries are collected from the keys under key, ignoring any that are just a
+// context or application suffix of key, such as peers.live.  The two forms
+// should not be mixed for the same key.
+func (c *Configuration) GetMap(key string, defaultValue ...map[string]string) (map[string]string, bool) {
+	raw, ok, source := c.getInternal(key)
+	str := strings.TrimPrefix(raw, "*EHE*")
+
+	hasDefault := len(defaultValue) > 0
+	defStr := ""
+	if hasDefault {
+		defStr = formatMap(defaultValue[0])
+	}
+
+	if ok && str != "" {
+		c.record(key, "map", hasDefault, defStr, raw, source)
+		return parseMap(str), true
+	}
+
+	names := c.mapEntryNames(key)
+	if len(names) == 0 {
+		if hasDefault {
+			c.record(key, "map", hasDefault, defStr, defStr, "DEFAULT")
+			return defaultValue[0], false
+		}
+		c.record(key, "map", hasDefault, defStr, "", "DEFAULT")
+		return map[string]string{}, false
+	}
+
+	m := make(map[string]string, len(names))
+	for _, name := range names {
+		entryKey := key + "." + name
+
+		val, found, source := c.getInternal(entryKey)
+		if !found {
+			continue
+		}
+
+		c.record(entryKey, "map", false, "", val, source)
+		m[name] = strings.TrimPrefix(val, "*EHE*")
+	}
+
+	return m, len(m) > 0
+}
+
+// mapEntryNames returns the entry names found under key, excluding suffixes
+// that only select a context or application, such as live, live.uk or app,
+// whether or not they are the current context.
+func (c *Configuration) mapEntryNames(key string) []string {
+	prefix := c.fullPrefix() + key + "."
+	contexts := c.contextNames()
+
+	var names []string
+	for _, k := range c.allKeys() {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		name := strings.Split(k[len(prefix):], ".")[0]
+		if _, found := contexts[name]; found {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return uniqueSorted(names)
+}
+
+func parseMap(str string) map[string]string {
+	m := make(map[string]string)
+
+	for _, pair := range strings.Split(str, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		k, v, _ := strings.Cut(pair, "=")
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	return m
+}
+
+func formatMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + m[k]
+	}
+
+	return strings.Join(pairs, ";")
+}
+
+// GetStructList fills out, which must be a pointer to a slice of structs,
+// from settings that use an indexed key convention:
+//
+//	peer[0].host      = localhost
+//	peer[0].port      = 8333
+//	peer[0].host.live = peer0.example.com
+//	peer[1].host      = 10.0.0.2
+//
+// Each field is resolved with the usual context fallback.  Fields are matched
+// by their `gocore:"name"` tag or, failing that, case-insensitively by name.
+// Supported field types are strings, bools, integers, floats and
+// time.Duration.  The bool return is false if no entries were found.
+func (c *Configuration) GetStructList(key string, out interface{}) (bool, error) {
+	ptr := reflect.ValueOf(out)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice || ptr.Elem().Type().Elem().Kind() != reflect.Struct {
+		return false, errors.New("GetStructList requires a pointer to a slice of structs")
+	}
+
+	slice := ptr.Elem()
+	elemType := slice.Type().Elem()
+
+	indexes := c.structListIndexes(key)
+
+	result := reflect.MakeSlice(slice.Type(), 0, len(indexes))
+
+	for _, index := range indexes {
+		elem := reflect.New(elemType).Elem()
+
+		for i := 0; i < elemType.NumField(); i++ {
+			field := elemType.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := field.Tag.Get("gocore")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			fieldKey, found := c.structFieldKey(key, index, name)
+			if !found {
+				continue
+			}
+
+			raw, ok, source := c.getInternal(fieldKey)
+			if !ok {
+				continue
+			}
+
+			c.record(fieldKey, field.Type.String(), false, "", raw, source)
+
+			if err := setField(elem.Field(i), strings.TrimPrefix(raw, "*EHE*")); err != nil {
+				return true, fmt.Errorf("failed to parse %s: %w", fieldKey, err)
+			}
+		}
+
+		result = reflect.Append(result, elem)
+	}
+
+	slice.Set(result)
+
+	return len(indexes) > 0, nil
+}
+
+// structListIndexes returns the sorted, distinct indexes used with key[n].
+func (c *Configuration) structListIndexes(key string) []int {
+	prefix := c.fullPrefix() + key + "["
+
+	seen := make(map[int]struct{})
+	for _, k := range c.allKeys() {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		end := strings.Index(k[len(prefix):], "]")
+		if end == -1 {
+			continue
+		}
+
+		index, err := strconv.Atoi(k[len(prefix) : len(prefix)+end])
+		if err != nil || index < 0 {
+			continue
+		}
+
+		seen[index] = struct{}{}
+	}
+
+	indexes := make([]int, 0, len(seen))
+	for index := range seen {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	return indexes
+}
+
+// structFieldKey finds the declared spelling of a field name for key[index],
+// matching case-insensitively.
+func (c *Configuration) structFieldKey(key string, index int, name string) (string, bool) {
+	element := fmt.Sprintf("%s[%d].", key, index)
+	prefix := c.fullPrefix() + element
+
+	for _, k := range c.allKeys() {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		field := strings.Split(k[len(prefix):], ".")[0]
+		if strings.EqualFold(field, name) {
+			return element + field, true
+		}
+	}
+
+	return "", false
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setField(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package gocore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMapSingleValue(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "endpoints = alice = http://a:8080; bob=http://b:8080\nendpoints.live = alice=https://a\n")
+
+	m, ok := cfg.GetMap("endpoints")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"alice": "http://a:8080", "bob": "http://b:8080"}, m)
+
+	cfg.context = "live"
+	m, ok = cfg.GetMap("endpoints")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"alice": "https://a"}, m)
+
+	m, ok = cfg.GetMap("missing_map", map[string]string{"x": "1"})
+	assert.False(t, ok)
+	assert.Equal(t, map[string]string{"x": "1"}, m)
+}
+
+func TestGetMapEntries(t *testing.T) {
+	cfg := newTestConfig(t, "live", `peers.alice         = http://a:8080
+peers.alice.live    = https://a.example.com
+peers.bob           = http://b:8080
+peers.carol.stage   = http://c:8080
+peers.dave.live.app = http://d-app
+`)
+	cfg.app = "app"
+
+	m, ok := cfg.GetMap("peers")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"alice": "https://a.example.com",
+		"bob":   "http://b:8080",
+		"dave":  "http://d-app",
+	}, m)
+
+	sources := make(map[string]string)
+	for _, r := range cfg.requestedSnapshot() {
+		sources[r.Key] = r.Source
+	}
+	assert.Equal(t, "peers.alice.live", sources["peers.alice"])
+	assert.Equal(t, "peers.dave.live.app", sources["peers.dave"])
+}
+
+func TestGetMapOtherContexts(t *testing.T) {
+	cfg := newTestConfig(t, "dev", `peers.alice      = http://a:8080
+peers.alice.live = https://a.example.com
+peers.live       = bob=https://b.example.com
+peers.stage.uk   = carol=https://c.example.com
+host             = localhost
+host.stage       = stage.example.com
+port             = 8080
+port.live        = 443
+port.stage       = 8443
+`)
+
+	m, ok := cfg.GetMap("peers")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"alice": "http://a:8080"}, m)
+}
+
+type testPeer struct {
+	Host    string
+	Port    int
+	TLS     bool          `gocore:"secure"`
+	Timeout time.Duration `gocore:"timeout"`
+	private string
+}
+
+func TestGetStructList(t *testing.T) {
+	cfg := newTestConfig(t, "live", `peer[0].host      = localhost
+peer[0].port      = 8333
+peer[0].host.live = peer0.example.com
+peer[0].secure    = true
+peer[0].timeout   = 3s
+peer[2].host      = 10.0.0.2
+`)
+
+	var peers []testPeer
+	ok, err := cfg.GetStructList("peer", &peers)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.Len(t, peers, 2)
+	assert.Equal(t, testPeer{Host: "peer0.example.com", Port: 8333, TLS: true, Timeout: 3 * time.Second}, peers[0])
+	assert.Equal(t, testPeer{Host: "10.0.0.2"}, peers[1])
+
+	for _, r := range cfg.requestedSnapshot() {
+		if r.Key == "peer[0].port" {
+			assert.Equal(t, "int", r.Type)
+		}
+	}
+
+	cfg.Set("peer[1].port", "lots")
+	_, err = cfg.GetStructList("peer", &peers)
+	assert.Error(t, err)
+
+	var missing []testPeer
+	ok, err = cfg.GetStructList("nopeer", &missing)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = cfg.GetStructList("peer", peers)
+	assert.Error(t, err)
+}
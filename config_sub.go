@@ -0,0 +1,117 @@
+package gocore
+
+import (
+	"sort"
+	"strings"
+)
+
+// Sub returns a view of the configuration scoped to the keys that start with
+// prefix.  If prefix does not already end in "_" or ".", an "_" separator is
+// added, so Sub("database").Get("host") reads database_host and
+// Sub("kafka.").Get("brokers") reads kafka.brokers.
+//
+// The view shares the parent's settings, context resolution, listeners and
+// request tracking, and requests are recorded under the full key.  Methods
+// that report on the whole configuration, such as Stats and Requested,
+// return the parent's report.
+func (c *Configuration) Sub(prefix string) *Configuration {
+	if !strings.HasSuffix(prefix, "_") && !strings.HasSuffix(prefix, ".") {
+		prefix += "_"
+	}
+
+	return &Configuration{parent: c, prefix: prefix}
+}
+
+// Keys returns the distinct keys, without any context or application suffix,
+// that are declared in the settings files or layers.  For a view returned by
+// Sub, only the keys under the prefix are returned, with the prefix removed.
+func (c *Configuration) Keys() []string {
+	prefix := c.fullPrefix()
+	contexts := c.contextNames()
+
+	var keys []string
+	for _, k := range c.allKeys() {
+		if strings.HasPrefix(k, prefix) && len(k) > len(prefix) {
+			keys = append(keys, baseKey(k, contexts)[len(prefix):])
+		}
+	}
+
+	return uniqueSorted(keys)
+}
+
+// root returns the configuration that holds the settings, which is c itself
+// unless c is a view returned by Sub.  Views hold nothing but their prefix, so
+// every method starts from the root: methods that take a key use
+// c.root() with c.qualify(key), and the others report on c.root().
+func (c *Configuration) root() *Configuration {
+	for c.parent != nil {
+		c = c.parent
+	}
+
+	return c
+}
+
+// qualify returns the full key for key in this view.
+func (c *Configuration) qualify(key string) string {
+	return c.fullPrefix() + key
+}
+
+// unqualify returns the entries of m whose keys are in this view, with the
+// prefix removed.
+func (c *Configuration) unqualify(m map[string]string) map[string]string {
+	prefix := c.fullPrefix()
+
+	scoped := make(map[string]string, len(m))
+	for k, v := range m {
+		if strings.HasPrefix(k, prefix) {
+			scoped[k[len(prefix):]] = v
+		}
+	}
+
+	return scoped
+}
+
+// fullPrefix returns the combined prefix of this view and its parents.
+func (c *Configuration) fullPrefix() string {
+	if c.parent == nil {
+		return ""
+	}
+
+	return c.parent.fullPrefix() + c.prefix
+}
+
+// allKeys returns every fully qualified key in the settings files and layers.
+func (c *Configuration) allKeys() []string {
+	c = c.root()
+
+	layers := c.getLayers()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0, len(c.confs))
+	for k := range c.confs {
+		keys = append(keys, k)
+	}
+
+	for _, l := range layers {
+		keys = append(keys, l.keys()...)
+	}
+
+	return keys
+}
+
+func uniqueSorted(values []string) []string {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+
+	result := make([]string, 0, len(set))
+	for v := range set {
+		result = append(result, v)
+	}
+	sort.Strings(result)
+
+	return result
+}
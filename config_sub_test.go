@@ -0,0 +1,161 @@
+package gocore
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSub(t *testing.T) {
+	cfg := newTestConfig(t, "live", `database_host      = localhost
+database_host.live = db.live
+database_port      = 5432
+database_timeout   = 5s
+kafka.brokers      = k1,k2
+kafka.brokers.live = k3
+other              = x
+`)
+
+	db := cfg.Sub("database")
+
+	v, ok := db.Get("host")
+	assert.True(t, ok)
+	assert.Equal(t, "db.live", v)
+
+	port, ok := db.GetInt("port")
+	assert.True(t, ok)
+	assert.Equal(t, 5432, port)
+
+	d, err, ok := db.GetDuration("timeout")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, d)
+
+	assert.Equal(t, []string{"host", "port", "timeout"}, db.Keys())
+	assert.Equal(t, "live", db.GetContext())
+
+	brokers, ok := cfg.Sub("kafka.").GetMulti("brokers", ",")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"k3"}, brokers)
+	assert.Equal(t, []string{"brokers"}, cfg.Sub("kafka.").Keys())
+	assert.Equal(t, []string{"database_host", "database_port", "database_timeout", "kafka.brokers", "other"}, cfg.Keys())
+
+	keys := make(map[string]bool)
+	for _, r := range cfg.requestedSnapshot() {
+		keys[r.Key] = true
+	}
+	assert.True(t, keys["database_host"])
+	assert.True(t, keys["kafka.brokers"])
+	assert.False(t, keys["host"])
+
+	all := db.GetAll()
+	assert.Equal(t, "5432", all["port"])
+	assert.NotContains(t, all, "other")
+}
+
+func TestSubSetAndListeners(t *testing.T) {
+	cfg := newTestConfig(t, "", "")
+
+	listener := newMockListener(1)
+	db := cfg.Sub("database_")
+	db.AddListener(listener)
+
+	db.Set("user", "admin")
+	assert.Equal(t, "database_user=admin", <-listener.ch)
+
+	v, _ := cfg.Get("database_user")
+	assert.Equal(t, "admin", v)
+
+	nested := cfg.Sub("a").Sub("b")
+	nested.Set("c", "1")
+	v, _ = cfg.Get("a_b_c")
+	assert.Equal(t, "1", v)
+	assert.Equal(t, []string{"c"}, nested.Keys())
+}
+
+// TestSubAllMethods calls every exported method through Sub views, which
+// have no maps of their own, so that a method that forgets to go through
+// root() or qualify() panics or leaves state on the view.
+func TestSubAllMethods(t *testing.T) {
+	cfg := &Configuration{
+		confs: map[string]string{
+			"database_host": "localhost",
+			"database_port": "5432",
+		},
+		context:  "dev",
+		requests: make(map[string]*requestRecord),
+	}
+
+	for _, view := range []*Configuration{cfg.Sub("database"), cfg.Sub("database").Sub("replica")} {
+		typ := reflect.TypeOf(view)
+
+		for i := 0; i < typ.NumMethod(); i++ {
+			method := typ.Method(i)
+
+			args := []reflect.Value{reflect.ValueOf(view)}
+			for j := 1; j < method.Type.NumIn(); j++ {
+				if method.Type.IsVariadic() && j == method.Type.NumIn()-1 {
+					break
+				}
+
+				args = append(args, subMethodArg(cfg, method.Type.In(j)))
+			}
+
+			assert.NotPanics(t, func() { method.Func.Call(args) }, method.Name)
+		}
+
+		fields := reflect.ValueOf(view).Elem()
+		for i := 0; i < fields.NumField(); i++ {
+			name := fields.Type().Field(i).Name
+			if name == "parent" || name == "prefix" {
+				continue
+			}
+
+			assert.True(t, fields.Field(i).IsZero(), "%s is set on the view", name)
+		}
+	}
+
+	// Every key was qualified with the prefix of the view
+	_, ok := cfg.Get("host")
+	assert.False(t, ok)
+	assert.NotContains(t, cfg.Keys(), "host")
+}
+
+// subMethodArg returns an argument of type typ for TestSubAllMethods.
+func subMethodArg(cfg *Configuration, typ reflect.Type) reflect.Value {
+	switch typ {
+	case reflect.TypeOf(ConfigChange{}):
+		return reflect.ValueOf(ConfigChange{Action: "set", Key: "host", Value: "db"})
+	case reflect.TypeOf(cfg):
+		return reflect.ValueOf(cfg)
+	case reflect.TypeOf((*io.Writer)(nil)).Elem():
+		return reflect.ValueOf(&bytes.Buffer{})
+	case reflect.TypeOf((*SettingsListener)(nil)).Elem():
+		return reflect.ValueOf(newMockListener(100))
+	case reflect.TypeOf((*interface{})(nil)).Elem():
+		return reflect.ValueOf(&[]struct{ Name string }{})
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf("host").Convert(typ)
+	case reflect.Map:
+		return reflect.MakeMap(typ)
+	case reflect.Func:
+		return reflect.MakeFunc(typ, func([]reflect.Value) []reflect.Value {
+			out := make([]reflect.Value, typ.NumOut())
+			for i := range out {
+				out[i] = reflect.Zero(typ.Out(i))
+			}
+
+			return out
+		})
+	default:
+		return reflect.Zero(typ)
+	}
+}
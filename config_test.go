@@ -1,12 +1,14 @@
 package gocore
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/ordishs/gocore/parser"
 	"github.com/ordishs/gocore/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -286,6 +288,22 @@ func TestGetUint8(t *testing.T) {
 // 	t.Log(Config().Stats())
 // }
 
+// newTestConfig returns a configuration for context with the settings in
+// content, as if they were read from settings.conf.  Unlike NewConfiguration,
+// it lets environment variables override keys by their bare names, as Config
+// does.
+func newTestConfig(t *testing.T, context, content string) *Configuration {
+	t.Helper()
+
+	cfg := &Configuration{
+		context:  context,
+		requests: make(map[string]*requestRecord),
+	}
+	require.NoError(t, cfg.AddSettings("settings.conf", []byte(content)))
+
+	return cfg
+}
+
 type mockListener struct {
 	ch chan string
 }
@@ -587,3 +605,65 @@ func TestRequestCountByKey(t *testing.T) {
 	_, present := counts["reqcount_never_requested_key"]
 	assert.False(t, present)
 }
+
+func TestAddSettingsNotifiesListeners(t *testing.T) {
+	cfg := NewConfiguration("dev", "")
+	require.NoError(t, cfg.AddSettings("settings.conf", []byte("name = app\nport = 8080\n")))
+
+	listener := newMockListener(2)
+	cfg.AddListener(listener)
+
+	require.NoError(t, cfg.AddSettings("settings_local.conf", []byte("name = local\nport = 8080\n")))
+
+	v, _ := cfg.Get("name")
+	assert.Equal(t, "local", v)
+
+	assert.Equal(t, "name=local", <-listener.ch)
+	assert.Empty(t, listener.ch)
+	assert.Equal(t, "settings_local.conf:1", cfg.originOf("name"))
+}
+
+func TestParseSettingsSyntax(t *testing.T) {
+	m := make(map[string]string)
+	origins := make(map[string]string)
+
+	err := parseSettings(m, origins, "settings.conf", `color=#fff
+link = http://example.com/#fragment # a comment
+title = "a # b"
+list = "a, \
+       b"
+pem = <<END
+line 1 # not a comment
+END
+broken = "unterminated
+after = 1
+`)
+
+	var errs parser.ErrorList
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 1)
+	assert.Equal(t, `settings.conf:9: broken: unterminated " quoted value`, errs[0].Error())
+
+	assert.Equal(t, "#fff", m["color"])
+	assert.Equal(t, "http://example.com/#fragment", m["link"])
+	assert.Equal(t, "a # b", m["title"])
+	assert.Equal(t, "a, b", m["list"])
+	assert.Equal(t, "line 1 # not a comment", m["pem"])
+	assert.Equal(t, "1", m["after"])
+	assert.Equal(t, "settings.conf:10", origins["after"])
+
+	// A malformed value is read as older versions read it
+	assert.Equal(t, `"unterminated`, m["broken"])
+}
+
+func TestAddSettingsSyntaxError(t *testing.T) {
+	cfg := NewConfiguration("dev", "")
+
+	err := cfg.AddSettings("extra.conf", []byte("a = 1\nb = \"unterminated\n"))
+	require.EqualError(t, err, `extra.conf:2: b: unterminated " quoted value`)
+
+	// The valid settings are still added
+	a, ok := cfg.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", a)
+}
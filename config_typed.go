@@ -0,0 +1,253 @@
+package gocore
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getParsed is the generic counterpart of getNumber for values that need a
+// custom parser.  typ is the name recorded against the request and format
+// converts a default value to the string shown in the request records.
+func getParsed[T any](c *Configuration, key string, typ string, parse func(string) (T, error), format func(T) string, defaultValue ...T) (T, bool, error) {
+	raw, ok, source := c.getInternal(key)
+	str := strings.TrimPrefix(raw, "*EHE*")
+
+	hasDefault := len(defaultValue) > 0
+	defStr := ""
+	if hasDefault {
+		defStr = format(defaultValue[0])
+	}
+
+	if str == "" || !ok {
+		if hasDefault {
+			c.record(key, typ, hasDefault, defStr, defStr, "DEFAULT")
+			return defaultValue[0], false, nil
+		}
+		c.record(key, typ, hasDefault, defStr, "", "DEFAULT")
+		var zero T
+		return zero, false, nil
+	}
+
+	c.record(key, typ, hasDefault, defStr, raw, source)
+
+	result, err := parse(str)
+	if err != nil {
+		var zero T
+		return zero, true, fmt.Errorf("failed to parse %q as %s: %w", str, typ, err)
+	}
+
+	return result, true, nil
+}
+
+var reByteSize = regexp.MustCompile(`^([0-9]*\.?[0-9]+)\s*([a-zA-Z]*)$`)
+
+var byteSizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1000,
+	"kb":  1000,
+	"m":   1000 * 1000,
+	"mb":  1000 * 1000,
+	"g":   1000 * 1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"t":   1000 * 1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseByteSize parses a size such as "512", "512MB", "1.5GB" or "1GiB".
+// KB, MB, GB and TB are powers of 1000 and KiB, MiB, GiB and TiB are powers
+// of 1024.  Units are case-insensitive.  A fraction must come to a whole
+// number of bytes, so "1.5KB" is 1500 but "1.5B" is an error.
+func ParseByteSize(s string) (uint64, error) {
+	matches := reByteSize.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+
+	multiplier, found := byteSizeUnits[strings.ToLower(matches[2])]
+	if !found {
+		return 0, fmt.Errorf("unknown unit %q in byte size %q", matches[2], s)
+	}
+
+	// The size is worked out exactly, as 1.1KB is 1100.0000000000002 in
+	// floating point
+	size, ok := new(big.Rat).SetString(matches[1])
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	size.Mul(size, new(big.Rat).SetInt64(multiplier))
+
+	if !size.IsInt() {
+		return 0, fmt.Errorf("byte size %q is not a whole number of bytes", s)
+	}
+
+	if !size.Num().IsUint64() {
+		return 0, fmt.Errorf("byte size %q is too large", s)
+	}
+
+	return size.Num().Uint64(), nil
+}
+
+func (c *Configuration) TryGetByteSize(key string, defaultValue ...uint64) (uint64, bool, error) {
+	return getParsed(c, key, "bytesize", ParseByteSize, func(v uint64) string {
+		return strconv.FormatUint(v, 10)
+	}, defaultValue...)
+}
+
+func (c *Configuration) GetByteSize(key string, defaultValue ...uint64) (uint64, bool) {
+	n, found, err := c.TryGetByteSize(key, defaultValue...)
+	if err != nil {
+		return n, false
+	}
+
+	return n, found
+}
+
+func (c *Configuration) TryGetTime(key string, defaultValue ...time.Time) (time.Time, bool, error) {
+	return getParsed(c, key, typeLabel[time.Time](), func(s string) (time.Time, error) {
+		return time.Parse(time.RFC3339, s)
+	}, func(v time.Time) string {
+		return v.Format(time.RFC3339)
+	}, defaultValue...)
+}
+
+func (c *Configuration) GetTime(key string, defaultValue ...time.Time) (time.Time, bool) {
+	t, found, err := c.TryGetTime(key, defaultValue...)
+	if err != nil {
+		return t, false
+	}
+
+	return t, found
+}
+
+func parseIP(s string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", s)
+	}
+
+	return ip, nil
+}
+
+func (c *Configuration) TryGetIP(key string, defaultValue ...net.IP) (net.IP, bool, error) {
+	return getParsed(c, key, typeLabel[net.IP](), parseIP, net.IP.String, defaultValue...)
+}
+
+func (c *Configuration) GetIP(key string, defaultValue ...net.IP) (net.IP, bool) {
+	ip, found, err := c.TryGetIP(key, defaultValue...)
+	if err != nil {
+		return ip, false
+	}
+
+	return ip, found
+}
+
+func (c *Configuration) TryGetCIDR(key string, defaultValue ...*net.IPNet) (*net.IPNet, bool, error) {
+	return getParsed(c, key, typeLabel[*net.IPNet](), func(s string) (*net.IPNet, error) {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}, (*net.IPNet).String, defaultValue...)
+}
+
+func (c *Configuration) GetCIDR(key string, defaultValue ...*net.IPNet) (*net.IPNet, bool) {
+	n, found, err := c.TryGetCIDR(key, defaultValue...)
+	if err != nil {
+		return n, false
+	}
+
+	return n, found
+}
+
+type hostPort struct {
+	host string
+	port int
+}
+
+func parseHostPort(s string) (hostPort, error) {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return hostPort{}, err
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return hostPort{}, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	return hostPort{host: host, port: int(port)}, nil
+}
+
+// TryGetHostPort returns the host and port of a "host:port" setting.  The
+// default, if given, is also in "host:port" form.
+func (c *Configuration) TryGetHostPort(key string, defaultValue ...string) (string, int, bool, error) {
+	var defaults []hostPort
+	if len(defaultValue) > 0 {
+		hp, err := parseHostPort(defaultValue[0])
+		if err != nil {
+			return "", 0, false, fmt.Errorf("invalid default %q: %w", defaultValue[0], err)
+		}
+		defaults = append(defaults, hp)
+	}
+
+	hp, found, err := getParsed(c, key, "hostport", parseHostPort, func(v hostPort) string {
+		return net.JoinHostPort(v.host, strconv.Itoa(v.port))
+	}, defaults...)
+
+	return hp.host, hp.port, found, err
+}
+
+func (c *Configuration) GetHostPort(key string, defaultValue ...string) (string, int, bool) {
+	host, port, found, err := c.TryGetHostPort(key, defaultValue...)
+	if err != nil {
+		return host, port, false
+	}
+
+	return host, port, found
+}
+
+func (c *Configuration) TryGetRegexp(key string, defaultValue ...*regexp.Regexp) (*regexp.Regexp, bool, error) {
+	return getParsed(c, key, typeLabel[*regexp.Regexp](), regexp.Compile, (*regexp.Regexp).String, defaultValue...)
+}
+
+func (c *Configuration) GetRegexp(key string, defaultValue ...*regexp.Regexp) (*regexp.Regexp, bool) {
+	re, found, err := c.TryGetRegexp(key, defaultValue...)
+	if err != nil {
+		return re, false
+	}
+
+	return re, found
+}
+
+// TryGetEnum returns the setting if it is one of the allowed values, and an
+// error if it is set to anything else.
+func (c *Configuration) TryGetEnum(key string, allowed []string, defaultValue ...string) (string, bool, error) {
+	return getParsed(c, key, "enum", func(s string) (string, error) {
+		for _, a := range allowed {
+			if s == a {
+				return s, nil
+			}
+		}
+
+		return "", fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	}, func(v string) string {
+		return v
+	}, defaultValue...)
+}
+
+func (c *Configuration) GetEnum(key string, allowed []string, defaultValue ...string) (string, bool) {
+	s, found, err := c.TryGetEnum(key, allowed, defaultValue...)
+	if err != nil {
+		return s, false
+	}
+
+	return s, found
+}
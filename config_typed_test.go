@@ -0,0 +1,206 @@
+package gocore
+
+import (
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const typedSettings = `cache_size     = 512MB
+buffer_size    = 1GiB
+bad_size       = 12 parsecs
+start_time     = 2024-03-01T12:00:00Z
+bind_ip        = 10.0.0.1
+allowed_net    = 192.168.0.0/16
+listen_address = localhost:8080
+bad_address    = localhost
+name_pattern   = ^[a-z]+$
+bad_pattern    = [
+log_level      = debug
+`
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]uint64{
+		"512":    512,
+		"512B":   512,
+		"1KB":    1000,
+		"1kib":   1024,
+		"512MB":  512 * 1000 * 1000,
+		"1GiB":   1 << 30,
+		"1.5 GB": 1500 * 1000 * 1000,
+		"1.1KB":  1100,
+		"0.5KiB": 512,
+	}
+
+	for s, expected := range tests {
+		n, err := ParseByteSize(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, n, s)
+	}
+
+	_, err := ParseByteSize("12 parsecs")
+	assert.Error(t, err)
+
+	_, err = ParseByteSize("MB")
+	assert.Error(t, err)
+
+	for _, s := range []string{"1.5B", "1.5", "0.0001KB", "1.0000000001GiB"} {
+		_, err = ParseByteSize(s)
+		assert.EqualError(t, err, `byte size "`+s+`" is not a whole number of bytes`, s)
+	}
+
+	// 16EiB is one more than the largest uint64
+	for _, s := range []string{"16777216TiB", "18446744073709551616", "20000000TB"} {
+		_, err = ParseByteSize(s)
+		assert.EqualError(t, err, `byte size "`+s+`" is too large`, s)
+	}
+
+	n, err := ParseByteSize("16777215TiB")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(16777215)<<40, n)
+}
+
+func TestGetByteSize(t *testing.T) {
+	cfg := newTestConfig(t, "dev", typedSettings)
+
+	n, ok := cfg.GetByteSize("cache_size")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(512*1000*1000), n)
+
+	n, ok = cfg.GetByteSize("buffer_size")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1<<30), n)
+
+	n, ok = cfg.GetByteSize("missing_size", 1024)
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1024), n)
+
+	_, ok, err := cfg.TryGetByteSize("bad_size")
+	assert.True(t, ok)
+	assert.Error(t, err)
+
+	_, ok = cfg.GetByteSize("bad_size")
+	assert.False(t, ok)
+}
+
+func TestGetTime(t *testing.T) {
+	cfg := newTestConfig(t, "dev", typedSettings)
+
+	tm, ok := cfg.GetTime("start_time")
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), tm)
+
+	def := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	tm, ok = cfg.GetTime("missing_time", def)
+	assert.False(t, ok)
+	assert.Equal(t, def, tm)
+
+	_, _, err := cfg.TryGetTime("log_level")
+	assert.Error(t, err)
+}
+
+func TestGetIPAndCIDR(t *testing.T) {
+	cfg := newTestConfig(t, "dev", typedSettings)
+
+	ip, ok := cfg.GetIP("bind_ip")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.1", ip.String())
+
+	ip, ok = cfg.GetIP("missing_ip", net.IPv4(127, 0, 0, 1))
+	assert.False(t, ok)
+	assert.Equal(t, "127.0.0.1", ip.String())
+
+	_, _, err := cfg.TryGetIP("log_level")
+	assert.Error(t, err)
+
+	n, ok := cfg.GetCIDR("allowed_net")
+	assert.True(t, ok)
+	assert.True(t, n.Contains(net.ParseIP("192.168.1.1")))
+	assert.False(t, n.Contains(net.ParseIP("10.0.0.1")))
+
+	_, _, err = cfg.TryGetCIDR("bind_ip")
+	assert.Error(t, err)
+}
+
+func TestGetHostPort(t *testing.T) {
+	cfg := newTestConfig(t, "dev", typedSettings)
+
+	host, port, ok := cfg.GetHostPort("listen_address")
+	assert.True(t, ok)
+	assert.Equal(t, "localhost", host)
+	assert.Equal(t, 8080, port)
+
+	host, port, ok = cfg.GetHostPort("missing_address", "0.0.0.0:9090")
+	assert.False(t, ok)
+	assert.Equal(t, "0.0.0.0", host)
+	assert.Equal(t, 9090, port)
+
+	_, _, ok, err := cfg.TryGetHostPort("bad_address")
+	assert.True(t, ok)
+	assert.Error(t, err)
+
+	_, _, _, err = cfg.TryGetHostPort("missing_address", "nonsense")
+	assert.Error(t, err)
+}
+
+func TestGetRegexp(t *testing.T) {
+	cfg := newTestConfig(t, "dev", typedSettings)
+
+	re, ok := cfg.GetRegexp("name_pattern")
+	assert.True(t, ok)
+	assert.True(t, re.MatchString("abc"))
+	assert.False(t, re.MatchString("ABC"))
+
+	re, ok = cfg.GetRegexp("missing_pattern", regexp.MustCompile(`\d+`))
+	assert.False(t, ok)
+	assert.Equal(t, `\d+`, re.String())
+
+	_, _, err := cfg.TryGetRegexp("bad_pattern")
+	assert.Error(t, err)
+}
+
+func TestGetEnum(t *testing.T) {
+	cfg := newTestConfig(t, "dev", typedSettings)
+
+	level, ok := cfg.GetEnum("log_level", []string{"debug", "info", "warn"})
+	assert.True(t, ok)
+	assert.Equal(t, "debug", level)
+
+	_, ok, err := cfg.TryGetEnum("log_level", []string{"info", "warn"})
+	assert.True(t, ok)
+	assert.ErrorContains(t, err, "must be one of info, warn")
+
+	_, ok = cfg.GetEnum("missing_level", []string{"info"})
+	assert.False(t, ok)
+
+	level, ok = cfg.GetEnum("missing_level", []string{"info", "warn"}, "warn")
+	assert.False(t, ok)
+	assert.Equal(t, "warn", level)
+}
+
+func TestTypedGettersRecordRequests(t *testing.T) {
+	cfg := newTestConfig(t, "dev", typedSettings)
+
+	_, _ = cfg.GetByteSize("cache_size")
+	_, _ = cfg.GetIP("missing_ip", net.IPv4(127, 0, 0, 1))
+
+	records := cfg.requestedSnapshot()
+	require.Len(t, records, 2)
+
+	byKey := make(map[string]requestRecord)
+	for _, r := range records {
+		byKey[r.Key] = r
+	}
+
+	assert.Equal(t, "bytesize", byKey["cache_size"].Type)
+	assert.Equal(t, "512MB", byKey["cache_size"].Value)
+	assert.Contains(t, byKey["cache_size"].Callers[0], "config_typed_test.go")
+
+	assert.Equal(t, "ip", byKey["missing_ip"].Type)
+	assert.Equal(t, "127.0.0.1", byKey["missing_ip"].DefaultValue)
+	assert.Equal(t, "DEFAULT", byKey["missing_ip"].Source)
+}
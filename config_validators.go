@@ -0,0 +1,179 @@
+package gocore
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync"
+)
+
+// keyValidator is a validator registered with AddValidator.
+type keyValidator struct {
+	pattern string
+	fn      func(key, newValue string) error
+}
+
+// validatorList holds the validators, which are shared with the
+// configurations for other contexts, as the layers are.
+type validatorList struct {
+	mu         sync.RWMutex
+	validators []keyValidator
+}
+
+// clone returns a copy of the list that does not change with it.
+func (l *validatorList) clone() *validatorList {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return &validatorList{validators: append([]keyValidator(nil), l.validators...)}
+}
+
+// validatorList returns the validators of c, creating the list if there is
+// none yet.
+func (c *Configuration) validatorList() *validatorList {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.validators == nil {
+		c.validators = &validatorList{}
+	}
+
+	return c.validators
+}
+
+// getValidators returns the validators that have been added so far.
+func (c *Configuration) getValidators() []keyValidator {
+	c.mu.RLock()
+	list := c.validators
+	c.mu.RUnlock()
+
+	if list == nil {
+		return nil
+	}
+
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+
+	return list.validators
+}
+
+// AddValidator registers fn to check changes to the keys that match
+// keyPattern before they are made.  The pattern uses path.Match syntax, so
+// max_* matches max_conns, and it also matches the context and application
+// variants of a key, so max_conns matches max_conns.live.
+//
+// Validators are consulted by Set, Unset and Revert, by the HTTP config API,
+// and when the secrets directory, remote settings or command line settings
+// are reloaded.  newValue is empty when the key is being removed.  If fn
+// returns an error, the change is not made and the old value stays in place.
+// The validators also apply to the configurations for other contexts returned
+// by Config, including those created before the validator was added.
+// AddValidator panics if keyPattern is malformed.
+//
+// fn is called while the change is being made, with other changes held back
+// until it returns.  It can read settings with Get and the typed getters, but
+// it must not change them with Set, Unset, Revert, AddSettings or
+// SetOverrides, or call Snapshot or Clone, as these wait for the change that
+// is calling fn and never return.
+func (c *Configuration) AddValidator(keyPattern string, fn func(key, newValue string) error) {
+	c, keyPattern = c.root(), c.qualify(keyPattern)
+
+	if _, err := path.Match(keyPattern, ""); err != nil {
+		panic(fmt.Sprintf("gocore: invalid validator key pattern %q: %v", keyPattern, err))
+	}
+
+	list := c.validatorList()
+
+	list.mu.Lock()
+	defer list.mu.Unlock()
+
+	list.validators = append(list.validators, keyValidator{pattern: keyPattern, fn: fn})
+}
+
+// matchesKey reports whether pattern matches key or any shorter dotted
+// prefix of it.
+func matchesKey(pattern, key string) bool {
+	for k := key; ; {
+		if ok, _ := path.Match(pattern, k); ok {
+			return true
+		}
+
+		pos := strings.LastIndex(k, ".")
+		if pos == -1 {
+			return false
+		}
+		k = k[:pos]
+	}
+}
+
+// validate runs the validators for key.  It is called without c.mu held, so
+// that validators can read other settings.
+func (c *Configuration) validate(key, newValue string) error {
+	for _, v := range c.getValidators() {
+		if !matchesKey(v.pattern, key) {
+			continue
+		}
+
+		if err := v.fn(key, newValue); err != nil {
+			return fmt.Errorf("%s rejected: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// validateLayer returns values with any rejected changes from current undone,
+// logging each rejection.
+func (c *Configuration) validateLayer(name string, current, values map[string]string) map[string]string {
+	if len(c.getValidators()) == 0 {
+		return values
+	}
+
+	accepted := make(map[string]string, len(values))
+	for k, v := range values {
+		accepted[k] = v
+	}
+
+	reject := func(key string, err error) {
+		log.Printf("WARN: Ignoring %s change - [%v]", name, err)
+
+		if old, found := current[key]; found {
+			accepted[key] = old
+		} else {
+			delete(accepted, key)
+		}
+	}
+
+	for k, v := range values {
+		if old, found := current[k]; found && old == v {
+			continue
+		}
+		if err := c.validate(k, v); err != nil {
+			reject(k, err)
+		}
+	}
+
+	for k := range current {
+		if _, found := values[k]; !found {
+			if err := c.validate(k, ""); err != nil {
+				reject(k, err)
+			}
+		}
+	}
+
+	return accepted
+}
+
+// confValue returns the value of key in the settings files, including any
+// runtime changes.
+func (c *Configuration) confValue(key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.confs[key]
+}
@@ -0,0 +1,213 @@
+package gocore
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func isNumber(key, newValue string) error {
+	if _, err := strconv.Atoi(newValue); err != nil {
+		return errors.New("must be a number")
+	}
+
+	return nil
+}
+
+func TestValidatorRejectsSet(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "max_conns = 10\nname = app\n")
+	cfg.AddValidator("max_*", isNumber)
+
+	listener := newMockListener(1)
+	cfg.AddListener(listener)
+	generation := cfg.Generation()
+
+	old, err := cfg.TrySet("max_conns", "lots")
+	assert.EqualError(t, err, "max_conns rejected: must be a number")
+	assert.Equal(t, "10", old)
+
+	// Set logs the rejection and leaves the old value in place.  The pattern
+	// also covers the context variants of the key.
+	assert.Equal(t, "10", cfg.Set("max_conns", "lots"))
+	assert.Equal(t, "", cfg.Set("max_conns.live", "lots"))
+
+	n, _ := cfg.GetInt("max_conns")
+	assert.Equal(t, 10, n)
+	assert.Empty(t, listener.ch)
+	assert.Equal(t, generation, cfg.Generation())
+
+	_, err = cfg.TryUnset("max_conns")
+	assert.EqualError(t, err, "max_conns rejected: must be a number")
+
+	// Other keys are not affected
+	_, err = cfg.TrySet("name", "lots")
+	assert.NoError(t, err)
+	assert.Equal(t, "name=lots", <-listener.ch)
+
+	_, err = cfg.TrySet("max_conns", "20")
+	require.NoError(t, err)
+	assert.Equal(t, "max_conns=20", <-listener.ch)
+}
+
+func TestValidatorSub(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "db_port = 5432\n")
+
+	db := cfg.Sub("db")
+	db.AddValidator("port", isNumber)
+
+	_, err := cfg.TrySet("db_port", "x")
+	assert.EqualError(t, err, "db_port rejected: must be a number")
+
+	_, err = db.TrySet("port", "5433")
+	assert.NoError(t, err)
+}
+
+func TestValidatorOtherContext(t *testing.T) {
+	// The configuration for the other context is created first
+	alt := Config("validatoralt")
+	Config().AddValidator("alt_validated_conns", isNumber)
+
+	_, err := alt.TrySet("alt_validated_conns", "lots")
+	assert.EqualError(t, err, "alt_validated_conns rejected: must be a number")
+
+	_, err = alt.TryUnset("alt_validated_conns")
+	assert.EqualError(t, err, "alt_validated_conns rejected: must be a number")
+
+	// A clone keeps the validators it was created with
+	cfg := newTestConfig(t, "dev", "")
+	clone := cfg.Clone()
+	cfg.AddValidator("max_conns", isNumber)
+
+	_, err = clone.TrySet("max_conns", "lots")
+	assert.NoError(t, err)
+}
+
+func TestValidatorReadsSettings(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "max_conns = 10\nmax_conns_limit = 100\n")
+
+	// Validators run while the change is being made, but can still read
+	// other settings
+	cfg.AddValidator("max_conns", func(key, newValue string) error {
+		limit, _ := cfg.GetInt("max_conns_limit")
+		if n, err := strconv.Atoi(newValue); err != nil || n > limit {
+			return errors.New("must be a number up to max_conns_limit")
+		}
+		return nil
+	})
+
+	_, err := cfg.TrySet("max_conns", "200")
+	assert.EqualError(t, err, "max_conns rejected: must be a number up to max_conns_limit")
+
+	_, err = cfg.TrySet("max_conns", "20")
+	assert.NoError(t, err)
+
+	require.NoError(t, cfg.AddSettings("settings_local.conf", []byte("max_conns = 300\n")))
+	cfg.SetCommandLineSettings(map[string]string{"max_conns": "400"})
+	_, _, err = cfg.TryRevert("max_conns")
+	assert.NoError(t, err)
+
+	n, _ := cfg.GetInt("max_conns")
+	assert.Equal(t, 10, n)
+}
+
+func TestValidatorRevert(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "mode = bad\n")
+	cfg.Set("mode", "good")
+
+	cfg.AddValidator("mode", func(key, newValue string) error {
+		if newValue == "bad" {
+			return errors.New("bad is not allowed")
+		}
+		return nil
+	})
+
+	_, found, err := cfg.TryRevert("mode")
+	assert.True(t, found)
+	assert.EqualError(t, err, "mode rejected: bad is not allowed")
+
+	v, _ := cfg.Get("mode")
+	assert.Equal(t, "good", v)
+}
+
+func TestValidatorLayerReload(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "max_conns"), []byte("10"), 0600))
+
+	cfg := NewConfiguration("dev", "")
+	cfg.AddValidator("max_conns", isNumber)
+	require.NoError(t, cfg.loadSecretsDir(dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "max_conns"), []byte("lots"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "max_idle"), []byte("lots"), 0600))
+	require.NoError(t, cfg.loadSecretsDir(dir))
+
+	n, _ := cfg.GetInt("max_conns")
+	assert.Equal(t, 10, n)
+
+	v, _ := cfg.Get("max_idle")
+	assert.Equal(t, "lots", v)
+
+	// A new layer only gets the values that pass
+	cfg.SetCommandLineSettings(map[string]string{"max_conns": "x"})
+	_, _, source := cfg.getInternal("max_conns")
+	assert.Equal(t, sourceSecretFile, source)
+}
+
+func TestValidatorAddSettings(t *testing.T) {
+	cfg := NewConfiguration("dev", "")
+	cfg.AddValidator("max_conns", isNumber)
+	require.NoError(t, cfg.AddSettings("settings.conf", []byte("max_conns = 10\nname = app\n")))
+
+	listener := newMockListener(2)
+	cfg.AddListener(listener)
+
+	require.NoError(t, cfg.AddSettings("settings_local.conf", []byte("max_conns = lots\nname = local\n")))
+
+	n, _ := cfg.GetInt("max_conns")
+	assert.Equal(t, 10, n)
+
+	v, _ := cfg.Get("name")
+	assert.Equal(t, "local", v)
+
+	assert.Equal(t, "name=local", <-listener.ch)
+	assert.Empty(t, listener.ch)
+	assert.Equal(t, "settings.conf:1", cfg.originOf("max_conns"))
+}
+
+func TestValidatorConfigAPI(t *testing.T) {
+	cfg := newTestConfig(t, "dev", "configEditToken = s3cret\nmax_conns = 10\n")
+	cfg.AddValidator("max_conns", isNumber)
+
+	rec := postChange(cfg, `{"action": "set", "key": "max_conns", "value": "lots"}`, map[string]string{"Authorization": "Bearer s3cret"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"error":"max_conns rejected: must be a number"`)
+
+	n, _ := cfg.GetInt("max_conns")
+	assert.Equal(t, 10, n)
+}
+
+func TestSocketValidator(t *testing.T) {
+	buf := &BufferWithClose{Buffer: &bytes.Buffer{}}
+	socketHandler := NewSocketHandler(Log("test"), buf)
+
+	Config().AddValidator("socket_validated_conns", isNumber)
+
+	socketHandler.handleConfig([]string{"config", "set", "socket_validated_conns=lots"})
+	assert.Equal(t, "  ERROR: socket_validated_conns rejected: must be a number\n\n", buf.String())
+
+	_, ok := Config().Get("socket_validated_conns")
+	assert.False(t, ok)
+}
+
+func TestAddValidatorBadPattern(t *testing.T) {
+	assert.Panics(t, func() {
+		NewConfiguration("dev", "").AddValidator("[", isNumber)
+	})
+}
@@ -0,0 +1,117 @@
+// Package gocoretest gives each test its own copy of the gocore
+// configuration, with settings and a context of its choosing:
+//
+//	func TestHandler(t *testing.T) {
+//		t.Parallel()
+//
+//		cfg := gocoretest.WithSettings(t, map[string]string{"timeout": "1s"})
+//		h := NewHandler(cfg)
+//		...
+//	}
+//
+// The copy is taken from gocore.Config() and is discarded when the test
+// finishes, so the global configuration is never changed and parallel tests
+// cannot see each other's settings.
+//
+// This isolation has a limit: only code that is given the returned
+// configuration sees the test's settings.  Code under test that calls
+// gocore.Config() or gocore.Flag() still reads the real settings, so it must
+// be changed to take a *gocore.Configuration, and to get its feature flags
+// with cfg.Flag, before it can be tested this way.
+package gocoretest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ordishs/gocore"
+)
+
+// testConfig is the configuration of one test and the settings overlaid on it.
+type testConfig struct {
+	mu       sync.Mutex
+	cfg      *gocore.Configuration
+	settings map[string]string
+}
+
+var (
+	mu      sync.Mutex
+	configs = make(map[testing.TB]*testConfig)
+)
+
+// get returns the configuration of t, creating it the first time it is asked
+// for and removing it when the test finishes.
+func get(t testing.TB) *testConfig {
+	mu.Lock()
+	defer mu.Unlock()
+
+	tc, found := configs[t]
+	if !found {
+		tc = &testConfig{
+			cfg:      gocore.Config().Clone(),
+			settings: make(map[string]string),
+		}
+		configs[t] = tc
+
+		t.Cleanup(func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			delete(configs, t)
+		})
+	}
+
+	return tc
+}
+
+// Config returns the test's configuration, which is a copy of gocore.Config()
+// until WithSettings or WithContext is called.
+func Config(t testing.TB) *gocore.Configuration {
+	t.Helper()
+
+	tc := get(t)
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	return tc.cfg
+}
+
+// WithSettings overlays settings on the test's configuration and returns it.
+// The settings take precedence over the settings files, the environment and
+// the command line, and are resolved with the usual context and application
+// fallback.  Calling WithSettings again in the same test adds to the earlier
+// settings.
+func WithSettings(t testing.TB, settings map[string]string) *gocore.Configuration {
+	t.Helper()
+
+	tc := get(t)
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	for k, v := range settings {
+		tc.settings[k] = v
+	}
+	tc.cfg.SetOverrides(tc.settings)
+
+	return tc.cfg
+}
+
+// WithContext switches the test's configuration to the given settings
+// context, such as live or stage, and returns it.  This replaces the
+// configuration returned by earlier calls, so it is best called first; any
+// settings from WithSettings are carried over.
+func WithContext(t testing.TB, context string) *gocore.Configuration {
+	t.Helper()
+
+	tc := get(t)
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.cfg = gocore.Config().Clone(context)
+	tc.cfg.SetOverrides(tc.settings)
+
+	return tc.cfg
+}
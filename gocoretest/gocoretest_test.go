@@ -0,0 +1,115 @@
+package gocoretest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ordishs/gocore"
+)
+
+func TestWithSettings(t *testing.T) {
+	cfg := WithSettings(t, map[string]string{"gocoretest_name": "first"})
+	WithSettings(t, map[string]string{"gocoretest_port": "8080"})
+
+	assert.Same(t, cfg, Config(t))
+
+	v, ok := cfg.Get("gocoretest_name")
+	assert.True(t, ok)
+	assert.Equal(t, "first", v)
+
+	port, _ := cfg.GetInt("gocoretest_port")
+	assert.Equal(t, 8080, port)
+
+	_, ok = gocore.Config().Get("gocoretest_name")
+	assert.False(t, ok)
+}
+
+func TestGlobalConfigUnchanged(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := WithSettings(t, map[string]string{
+		"gocoretest_global":      "from test",
+		"flag.gocoretest_global": "true",
+	})
+
+	// Only code given the test's configuration sees its settings
+	v, _ := cfg.Get("gocoretest_global")
+	assert.Equal(t, "from test", v)
+	assert.True(t, cfg.Flag("gocoretest_global").Enabled(ctx, "alice"))
+
+	_, ok := gocore.Config().Get("gocoretest_global")
+	assert.False(t, ok)
+	assert.False(t, gocore.Flag("gocoretest_global").Enabled(ctx, "alice"))
+}
+
+func TestWithSettingsOverridesEnv(t *testing.T) {
+	t.Setenv("gocoretest_env", "from env")
+
+	cfg := WithSettings(t, map[string]string{"gocoretest_env": "from test"})
+
+	v, _ := cfg.Get("gocoretest_env")
+	assert.Equal(t, "from test", v)
+}
+
+func TestWithContext(t *testing.T) {
+	cfg := WithSettings(t, map[string]string{
+		"gocoretest_url":       "http://localhost",
+		"gocoretest_url.live":  "https://example.com",
+		"gocoretest_url.stage": "https://stage.example.com",
+	})
+
+	v, _ := cfg.Get("gocoretest_url")
+	assert.Equal(t, "http://localhost", v)
+
+	live := WithContext(t, "live")
+	assert.Equal(t, "live", live.GetContext())
+	assert.Same(t, live, Config(t))
+
+	v, _ = live.Get("gocoretest_url")
+	assert.Equal(t, "https://example.com", v)
+
+	assert.NotEqual(t, "live", gocore.Config().GetContext())
+}
+
+func TestParallel(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		value := fmt.Sprintf("value%d", i)
+		context := fmt.Sprintf("ctx%d", i)
+
+		t.Run(value, func(t *testing.T) {
+			t.Parallel()
+
+			WithContext(t, context)
+			cfg := WithSettings(t, map[string]string{"gocoretest_parallel": value})
+
+			for j := 0; j < 100; j++ {
+				v, _ := cfg.Get("gocoretest_parallel")
+				require.Equal(t, value, v)
+				require.Equal(t, context, cfg.GetContext())
+			}
+		})
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	var sub testing.TB
+
+	t.Run("sub", func(t *testing.T) {
+		sub = t
+		WithSettings(t, map[string]string{"gocoretest_cleanup": "yes"})
+
+		mu.Lock()
+		_, found := configs[t]
+		mu.Unlock()
+		assert.True(t, found)
+	})
+
+	mu.Lock()
+	_, found := configs[sub]
+	mu.Unlock()
+	assert.False(t, found)
+}
@@ -0,0 +1,102 @@
+package parser
+
+import "strings"
+
+// Doc is the documentation of a setting: the comment lines above it and the
+// annotations that apply to it.  Settings in a group are written between
+//
+//	# @group: name
+//	...
+//	# @endgroup
+//
+// and "# @group: name compact" marks a group whose settings are aligned
+// together.  "# @type duration" (or "# @type: duration") above a setting
+// gives its type.
+type Doc struct {
+	// Comments are the comment lines above the setting, without the #.
+	// Annotations and commented out settings, such as
+	// "# url = http://localhost", are left out.
+	Comments []string
+	Group    string
+	Compact  bool
+	Type     string
+}
+
+// GroupStart reports whether line is a @group annotation, and returns the
+// name of the group and whether it is compact.
+func GroupStart(line string) (name string, compact bool, ok bool) {
+	if !strings.HasPrefix(line, "# @group:") {
+		return "", false, false
+	}
+
+	name = strings.TrimSpace(strings.TrimPrefix(line, "# @group:"))
+	if strings.HasSuffix(name, " compact") {
+		return strings.TrimSuffix(name, " compact"), true, true
+	}
+
+	return name, false, true
+}
+
+// IsGroupEnd reports whether line ends a group.
+func IsGroupEnd(line string) bool {
+	return line == "# @endgroup"
+}
+
+// TypeAnnotation reports whether line is a @type annotation, and returns the
+// type.
+func TypeAnnotation(line string) (string, bool) {
+	if !strings.HasPrefix(line, "# @type") {
+		return "", false
+	}
+
+	typ := strings.TrimSpace(strings.TrimPrefix(line, "# @type"))
+
+	return strings.TrimSpace(strings.TrimPrefix(typ, ":")), true
+}
+
+// Document returns the documentation of each setting in entries, indexed
+// like entries.  Comment entries get an empty Doc.
+func Document(entries []Entry) []Doc {
+	docs := make([]Doc, len(entries))
+
+	var (
+		pending []string
+		typ     string
+		group   string
+		compact bool
+	)
+
+	for i, entry := range entries {
+		line := entry.Comment
+
+		if entry.Kind == Setting {
+			docs[i] = Doc{Comments: pending, Group: group, Compact: compact, Type: typ}
+			pending = nil
+			typ = ""
+			continue
+		}
+
+		if name, c, ok := GroupStart(line); ok {
+			group, compact = name, c
+			continue
+		}
+
+		if IsGroupEnd(line) {
+			group, compact = "", false
+			continue
+		}
+
+		if t, ok := TypeAnnotation(line); ok {
+			typ = t
+			continue
+		}
+
+		// Lines such as "# key = value" are commented out settings, not
+		// documentation
+		if !strings.Contains(line, "=") {
+			pending = append(pending, strings.TrimSpace(line[1:]))
+		}
+	}
+
+	return docs
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument(t *testing.T) {
+	entries, err := Parse("settings.conf", []byte(`# The database host
+# used by every service
+host      = localhost
+host.live = db.example.com
+
+# @group: timeouts compact
+# How long to wait
+# @type: duration
+read_timeout = 5s
+# write_timeout = 10s
+# @endgroup
+
+port = 8080
+`))
+	require.NoError(t, err)
+
+	docs := make(map[string]Doc)
+	for i, doc := range Document(entries) {
+		if entries[i].Kind == Setting {
+			docs[entries[i].Key] = doc
+		}
+	}
+
+	assert.Equal(t, Doc{Comments: []string{"The database host", "used by every service"}}, docs["host"])
+	assert.Equal(t, Doc{}, docs["host.live"])
+	assert.Equal(t, Doc{Comments: []string{"How long to wait"}, Group: "timeouts", Compact: true, Type: "duration"}, docs["read_timeout"])
+	assert.Equal(t, Doc{}, docs["port"])
+}
+
+func TestAnnotations(t *testing.T) {
+	name, compact, ok := GroupStart("# @group: timeouts")
+	assert.Equal(t, "timeouts", name)
+	assert.False(t, compact)
+	assert.True(t, ok)
+
+	_, _, ok = GroupStart("# group: timeouts")
+	assert.False(t, ok)
+
+	assert.True(t, IsGroupEnd("# @endgroup"))
+
+	typ, ok := TypeAnnotation("# @type bytesize")
+	assert.Equal(t, "bytesize", typ)
+	assert.True(t, ok)
+}
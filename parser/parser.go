@@ -0,0 +1,379 @@
+// Package parser reads gocore settings files.
+//
+// A settings file is made up of comment lines, which start with #, and
+// key = value lines.  Values can be written in several ways:
+//
+//	plain  = a value # a # after whitespace starts a comment
+//	color  = \#fff
+//	link   = http://example.com/#fragment
+//	double = "tab\tseparated, with \"escapes\" and a # that is not a comment"
+//	single = 'no escapes apart from \' and \\'
+//	long   = "first part, \
+//	         second part"
+//	pem    = <<EOF
+//	-----BEGIN CERTIFICATE-----
+//	...
+//	-----END CERTIFICATE-----
+//	EOF
+//
+// In plain values, # only starts a comment when it follows whitespace, so
+// color=#fff needs no escaping but color = \#fff does.  \# is a literal #,
+// and any other \ in a plain value is kept as it is.  In double quoted
+// values, unknown escapes such as \d are kept as they are, and a \ at the end
+// of a line joins the next line on with its leading whitespace removed.
+// Heredoc blocks run until a line that contains only the tag, and their lines
+// are kept exactly as written, without the final newline.
+//
+// A quoted value or heredoc that is not closed is reported as an error, but
+// the setting is still read the way older versions of gocore read it: up to
+// the first #, with surrounding double quotes removed.
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is the type of an Entry.
+type Kind int
+
+const (
+	// Comment is a line starting with #.
+	Comment Kind = iota
+	// Setting is a key = value declaration, which may span several lines.
+	Setting
+)
+
+// Entry is a comment line or a setting.
+type Entry struct {
+	Kind Kind
+	// Line is the line number the entry starts on, starting at 1.
+	Line int
+	Key  string
+	// Value is the setting's value with quotes, escapes, continuations and
+	// heredoc markers resolved.
+	Value string
+	// Raw is the value exactly as written, excluding any trailing comment,
+	// so that the file can be rewritten without changing its meaning.
+	Raw string
+	// Comment is the whole line, including the #, for Comment entries, and
+	// the text of the trailing comment, without the #, for settings.
+	Comment string
+}
+
+// Error is a syntax error at a line in a settings file.
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// ErrorList is the list of syntax errors in a settings file.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+type parser struct {
+	filename string
+	lines    []string
+	pos      int
+	entries  []Entry
+	errors   ErrorList
+}
+
+// Parse parses the settings in src.  filename is only used in error
+// messages.  Lines that are not settings are skipped, settings with malformed
+// values are read as older versions read them, and the rest of the file is
+// still parsed, so the entries are returned together with an ErrorList if
+// there were any errors.
+func Parse(filename string, src []byte) ([]Entry, error) {
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+
+	p := &parser{
+		filename: filename,
+		lines:    strings.Split(text, "\n"),
+	}
+
+	for p.pos < len(p.lines) {
+		p.parseLine()
+	}
+
+	if len(p.errors) > 0 {
+		return p.entries, p.errors
+	}
+
+	return p.entries, nil
+}
+
+func (p *parser) errorf(line int, format string, args ...interface{}) {
+	p.errors = append(p.errors, &Error{File: p.filename, Line: line, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) parseLine() {
+	lineNum := p.pos + 1
+	line := strings.TrimSpace(p.lines[p.pos])
+	p.pos++
+
+	if line == "" {
+		return
+	}
+
+	if strings.HasPrefix(line, "#") {
+		p.entries = append(p.entries, Entry{Kind: Comment, Line: lineNum, Comment: line})
+		return
+	}
+
+	eq := strings.Index(line, "=")
+	if eq == -1 {
+		p.errorf(lineNum, "expected key = value, got %q", line)
+		return
+	}
+
+	key := strings.TrimSpace(line[:eq])
+	if key == "" {
+		p.errorf(lineNum, "missing key before =")
+		return
+	}
+
+	entry := Entry{Kind: Setting, Line: lineNum, Key: key}
+
+	rest := strings.TrimLeft(line[eq+1:], " \t")
+	spaced := len(rest) < len(line[eq+1:])
+
+	var err error
+
+	switch {
+	case strings.HasPrefix(rest, "<<"):
+		err = p.heredoc(&entry, rest)
+	case strings.HasPrefix(rest, `"`), strings.HasPrefix(rest, "'"):
+		err = p.quoted(&entry, rest)
+	default:
+		p.plain(&entry, rest, spaced)
+	}
+
+	if err != nil {
+		p.errorf(lineNum, "%s: %v", key, err)
+
+		// Keep the setting, read as older versions read it, rather than
+		// dropping it from a file that used to work
+		p.pos = lineNum
+		entry = Entry{Kind: Setting, Line: lineNum, Key: key}
+		legacyValue(&entry, line[eq+1:])
+	}
+
+	p.entries = append(p.entries, entry)
+}
+
+// legacyValue reads a value the way the original line based parser did: the
+// value ends at the first # and a pair of surrounding double quotes is
+// removed, with no escapes.
+func legacyValue(entry *Entry, s string) {
+	raw, comment, found := strings.Cut(s, "#")
+	raw = strings.TrimSpace(raw)
+
+	value := raw
+	if len(value) > 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+
+	entry.Value = value
+	entry.Raw = raw
+	if found {
+		entry.Comment = strings.TrimSpace(comment)
+	}
+}
+
+// nextLine returns the next line for a value that continues, or false at the
+// end of the file.
+func (p *parser) nextLine() (string, bool) {
+	if p.pos >= len(p.lines) {
+		return "", false
+	}
+
+	line := p.lines[p.pos]
+	p.pos++
+
+	return line, true
+}
+
+// trailingComment handles what follows a quoted value or heredoc tag, which
+// must be nothing or a comment.
+func trailingComment(entry *Entry, rest, after string) error {
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %q after %s", rest, after)
+	}
+
+	entry.Comment = strings.TrimSpace(rest[1:])
+
+	return nil
+}
+
+// plain reads an unquoted value.  spaced is true if there was whitespace
+// before s, in which case a # at the start of s begins a comment.  A \ at the
+// end of a plain value is kept as it is, as in C:\dir\.
+func (p *parser) plain(entry *Entry, s string, spaced bool) {
+	var value strings.Builder
+
+	i := 0
+
+scan:
+	for ; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '\\' && i+1 < len(s) && s[i+1] == '#':
+			value.WriteByte('#')
+			i++
+
+		case ch == '#' && ((i == 0 && spaced) || (i > 0 && (s[i-1] == ' ' || s[i-1] == '\t'))):
+			entry.Comment = strings.TrimSpace(s[i+1:])
+			break scan
+
+		default:
+			value.WriteByte(ch)
+		}
+	}
+
+	entry.Value = strings.TrimRight(value.String(), " \t")
+	entry.Raw = strings.TrimRight(s[:i], " \t")
+}
+
+func (p *parser) quoted(entry *Entry, s string) error {
+	quote := s[0]
+
+	var value, raw strings.Builder
+	raw.WriteByte(quote)
+
+	i := 1
+	for {
+		if i >= len(s) {
+			return fmt.Errorf("unterminated %c quoted value", quote)
+		}
+
+		ch := s[i]
+
+		if ch == quote {
+			raw.WriteString(s[1 : i+1])
+			entry.Value = value.String()
+			entry.Raw = raw.String()
+			return trailingComment(entry, s[i+1:], "quoted value")
+		}
+
+		if ch != '\\' {
+			value.WriteByte(ch)
+			i++
+			continue
+		}
+
+		if i == len(s)-1 {
+			// A \ at the end of the line continues the value on the next one
+			next, ok := p.nextLine()
+			if !ok {
+				return fmt.Errorf("unterminated %c quoted value", quote)
+			}
+			raw.WriteString(s[1:] + "\n")
+			trimmed := strings.TrimLeft(next, " \t")
+			raw.WriteString(next[:len(next)-len(trimmed)])
+			s = " " + trimmed
+			i = 1
+			continue
+		}
+
+		esc := s[i+1]
+		i += 2
+
+		if quote == '\'' {
+			switch esc {
+			case '\'', '\\':
+				value.WriteByte(esc)
+			default:
+				value.WriteByte('\\')
+				value.WriteByte(esc)
+			}
+			continue
+		}
+
+		switch esc {
+		case 'n':
+			value.WriteByte('\n')
+		case 't':
+			value.WriteByte('\t')
+		case 'r':
+			value.WriteByte('\r')
+		case '"', '\'', '\\', '#':
+			value.WriteByte(esc)
+		default:
+			// Unknown escapes are kept, so "\d+" is the regexp \d+
+			value.WriteByte('\\')
+			value.WriteByte(esc)
+		}
+	}
+}
+
+func (p *parser) heredoc(entry *Entry, s string) error {
+	spec := strings.TrimPrefix(s, "<<")
+
+	end := 0
+	for end < len(spec) && isTagChar(spec[end], end) {
+		end++
+	}
+
+	tag := spec[:end]
+	if tag == "" {
+		return fmt.Errorf("missing heredoc tag after <<")
+	}
+
+	if err := trailingComment(entry, spec[end:], "heredoc tag"); err != nil {
+		return err
+	}
+
+	var lines []string
+	for {
+		line, ok := p.nextLine()
+		if !ok {
+			return fmt.Errorf("heredoc %s is not terminated", tag)
+		}
+
+		if strings.TrimSpace(line) == tag {
+			break
+		}
+
+		lines = append(lines, line)
+	}
+
+	entry.Value = strings.Join(lines, "\n")
+	entry.Raw = "<<" + tag + "\n" + entry.Value
+	if len(lines) > 0 {
+		entry.Raw += "\n"
+	}
+	entry.Raw += tag
+
+	return nil
+}
+
+func isTagChar(ch byte, pos int) bool {
+	switch {
+	case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch == '_':
+		return true
+	case ch >= '0' && ch <= '9':
+		return pos > 0
+	}
+
+	return false
+}
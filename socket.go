@@ -64,6 +64,8 @@ func (h *SocketHandler) Handle() {
 			h.handleLogLevel(s)
 		case "sample":
 			h.handleSample(s)
+		case "flags":
+			_ = h.write("\n" + FormatFlags(Flags()) + "\n")
 		case "status":
 			h.sendStatus()
 		case "quit":
@@ -112,6 +114,25 @@ func (h *SocketHandler) handleConfig(r []string) {
 		requested := Config().Requested()
 		_ = h.write(fmt.Sprintf("\n%s\n\n", requested))
 
+	case "coverage":
+		coverage := Config().Coverage()
+		_ = h.write(fmt.Sprintf("\n%s\n", coverage))
+
+	case "diff":
+		if len(r) < 3 {
+			_ = h.write("  Invalid number of parameters. Use 'help' to see the syntax.\n\n")
+			return
+		}
+
+		cfg := Config()
+		diffs := cfg.Diff(r[2])
+		_ = h.write("\n" + FormatDiff(diffs, cfg.GetContext(), r[2]) + "\n")
+
+	case "docs":
+		var builder strings.Builder
+		_ = Config().WriteDocsMarkdown(&builder)
+		_ = h.write("\n" + builder.String() + "\n")
+
 	case "show":
 		stats := Config().Stats()
 		_ = h.write(stats + "\n\n")
@@ -158,8 +179,10 @@ func (h *SocketHandler) handleConfig(r []string) {
 			return
 		}
 
-		oldValue := Config().Set(key, value)
-		if oldValue == value {
+		oldValue, err := Config().TrySet(key, value)
+		if err != nil {
+			_ = h.write(fmt.Sprintf("  ERROR: %v\n\n", err))
+		} else if oldValue == value {
 			_ = h.write("  No change\n\n")
 		} else if oldValue == "" {
 			_ = h.write(fmt.Sprintf("  Created new setting: %s=%s\n\n", key, value))
@@ -172,13 +195,29 @@ func (h *SocketHandler) handleConfig(r []string) {
 			_ = h.write("  Invalid number of parameters. Use 'help' to see the syntax.\n\n")
 			return
 		}
-		oldValue := Config().Unset(r[2])
-		if oldValue == "" {
+		oldValue, err := Config().TryUnset(r[2])
+		if err != nil {
+			_ = h.write(fmt.Sprintf("  ERROR: %v\n\n", err))
+		} else if oldValue == "" {
 			_ = h.write("  No change\n\n")
 		} else {
 			_ = h.write(fmt.Sprintf("  Removed setting: %s=%s\n\n", r[2], oldValue))
 		}
 
+	case "revert":
+		if len(r) < 3 {
+			_ = h.write("  Invalid number of parameters. Use 'help' to see the syntax.\n\n")
+			return
+		}
+		value, found, err := Config().TryRevert(r[2])
+		if err != nil {
+			_ = h.write(fmt.Sprintf("  ERROR: %v\n\n", err))
+		} else if !found {
+			_ = h.write("  No change\n\n")
+		} else {
+			_ = h.write(fmt.Sprintf("  Reverted setting: %s=%s\n\n", r[2], maskSecrets(value)))
+		}
+
 	default:
 		_ = h.write("  Invalid command. Use 'help' to see the syntax.\n\n")
 	}
@@ -289,9 +328,13 @@ Commands:
   config
     show                    Show all configuration settings
     requested              Show all requested configuration settings
+    coverage               Show declared settings that are never requested and requested settings that are not declared
+    diff <context>         Show the settings whose effective values differ in another context
+    docs                   Show the settings reference in Markdown
     get <key>              Get a configuration setting
     set <key> <value>      Set a configuration setting
     unset <key>            Remove a configuration setting
+    revert <key>           Restore a setting that was changed with set or unset to its value from the settings files
 
   loglevel <level>         Set the log level (DEBUG, INFO, WARN, ERROR, FATAL)
 
@@ -305,6 +348,8 @@ Commands:
     off                    Turn off sampling for this connection
     clear                  Clear all sample connections
 
+  flags                    Show the feature flags and how often they were enabled and disabled
+
   status                   Show current status
 
   help                     Show this help message
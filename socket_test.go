@@ -60,6 +60,16 @@ func TestSocketHandleConfig(t *testing.T) {
 			args: []string{"", "set", "bob2", "simon says again"},
 			want: "  Updated setting: bob2 simon says -> simon says again\n\n",
 		},
+		{
+			name: "revert bob2",
+			args: []string{"", "revert", "bob2"},
+			want: "  Reverted setting: bob2=\n\n",
+		},
+		{
+			name: "revert bob2 again",
+			args: []string{"", "revert", "bob2"},
+			want: "  No change\n\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -82,3 +92,25 @@ func TestSocketHandleConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestSocketConfigCoverage(t *testing.T) {
+	buf := &BufferWithClose{Buffer: &bytes.Buffer{}}
+	socketHandler := NewSocketHandler(Log("test"), buf)
+
+	Config().Get("socket_coverage_undeclared")
+
+	socketHandler.handleConfig([]string{"config", "coverage"})
+
+	result := buf.String()
+	assert.Contains(t, result, "UNUSED (declared but never requested)")
+	assert.Contains(t, result, "socket_coverage_undeclared")
+}
+
+func TestSocketConfigDocs(t *testing.T) {
+	buf := &BufferWithClose{Buffer: &bytes.Buffer{}}
+	socketHandler := NewSocketHandler(Log("test"), buf)
+
+	socketHandler.handleConfig([]string{"config", "docs"})
+
+	assert.Contains(t, buf.String(), "# Settings reference")
+}
@@ -47,6 +47,16 @@ func encrypt(str string) (string, error) {
 	return fmt.Sprintf("*EHE*%x", ciphertext), nil
 }
 
+// EncryptSetting returns str in the *EHE* form that DecryptSetting reads.  A
+// value that is already encrypted is returned unchanged.
+func EncryptSetting(str string) (string, error) {
+	if strings.HasPrefix(str, "*EHE*") {
+		return str, nil
+	}
+
+	return encrypt(str)
+}
+
 // DecryptSetting will return *EHE* + plaintext if the settings if prefixed with "*EHE*" - Extremely High Encryption
 func DecryptSetting(str string) (string, error) {
 	if !strings.HasPrefix(str, "*EHE*") {
@@ -52,3 +52,20 @@ func TestDecryptSetting(t *testing.T) {
 
 	t.Logf("%s -> %s\n", val, res)
 }
+
+func TestEncryptSettingRoundTrip(t *testing.T) {
+	c, err := EncryptSetting("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := EncryptSetting(c)
+	if err != nil || again != c {
+		t.Errorf("expected an encrypted value to be unchanged, got %q, %v", again, err)
+	}
+
+	res, err := DecryptSetting(c)
+	if err != nil || res != "*EHE*s3cret" {
+		t.Errorf("expected *EHE*s3cret, got %q, %v", res, err)
+	}
+}